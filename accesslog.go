@@ -0,0 +1,188 @@
+package httprouter
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// AccessLogFormat selects the output format of an AccessLog.
+type AccessLogFormat int
+
+const (
+	// The Common Log Format of the NCSA HTTPd.
+	CommonLogFormat AccessLogFormat = iota
+
+	// The Common Log Format extended by the referer and the user agent.
+	CombinedLogFormat
+
+	// One JSON object per line, including the route, the parameters, the
+	// routing decision and the duration.
+	JSONLogFormat
+)
+
+// AccessLog writes a line for every request to Out.
+//
+// The access log is both a middleware measuring the requests and the hooks of
+// the router recording its decisions. This way it also covers the replies
+// generated by the router itself, like redirects and 405:
+//
+//	accessLog := httprouter.NewAccessLog(os.Stdout, httprouter.JSONLogFormat)
+//	router.Hooks = accessLog
+//	log.Fatal(http.ListenAndServe(":8080", accessLog.Middleware(router)))
+type AccessLog struct {
+	Out    io.Writer
+	Format AccessLogFormat
+
+	mu sync.Mutex // serializes writes to Out
+}
+
+// NewAccessLog returns a new access log writing to out in the given format.
+func NewAccessLog(out io.Writer, format AccessLogFormat) *AccessLog {
+	return &AccessLog{Out: out, Format: format}
+}
+
+// accessEntry is the line of a request, filled in while serving it.
+type accessEntry struct {
+	Time      time.Time         `json:"time"`
+	Remote    string            `json:"remote"`
+	User      string            `json:"user,omitempty"`
+	Method    string            `json:"method"`
+	URI       string            `json:"uri"`
+	Proto     string            `json:"proto"`
+	Route     string            `json:"route,omitempty"`
+	Params    map[string]string `json:"params,omitempty"`
+	Event     string            `json:"event,omitempty"`
+	Location  string            `json:"location,omitempty"`
+	Status    int               `json:"status"`
+	Bytes     int64             `json:"bytes"`
+	Duration  float64           `json:"duration"` // in seconds
+	Referer   string            `json:"referer,omitempty"`
+	UserAgent string            `json:"user_agent,omitempty"`
+}
+
+type accessEntryKey struct{}
+
+// Middleware returns a handler writing a line for every request served by
+// next.
+func (l *AccessLog) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		// capture the request before it is passed on
+		entry := &accessEntry{
+			Time:      time.Now(),
+			Remote:    req.RemoteAddr,
+			Method:    req.Method,
+			URI:       req.RequestURI,
+			Proto:     req.Proto,
+			Referer:   req.Referer(),
+			UserAgent: req.UserAgent(),
+		}
+		if host, _, err := net.SplitHostPort(req.RemoteAddr); err == nil {
+			entry.Remote = host
+		}
+		if entry.URI == "" {
+			entry.URI = req.URL.RequestURI()
+		}
+		if user, _, ok := req.BasicAuth(); ok {
+			entry.User = user
+		} else if req.URL.User != nil {
+			entry.User = req.URL.User.Username()
+		}
+
+		rw, w := wrapWriter(w)
+		defer l.finish(entry, rw)
+
+		next.ServeHTTP(w, req.WithContext(context.WithValue(req.Context(), accessEntryKey{}, entry)))
+	})
+}
+
+func (l *AccessLog) finish(entry *accessEntry, w *responseWriter) {
+	rcv := recover()
+	entry.Duration = time.Since(entry.Time).Seconds()
+	entry.Status = w.status
+	entry.Bytes = w.written
+	if rcv != nil {
+		entry.Status = http.StatusInternalServerError
+	} else if entry.Status == 0 {
+		entry.Status = http.StatusOK
+	}
+
+	l.write(entry)
+	if rcv != nil {
+		panic(rcv)
+	}
+}
+
+// OnEvent records the matched route and the routing decision for the
+// request.
+func (l *AccessLog) OnEvent(ev Event) {
+	entry, _ := ev.Request.Context().Value(accessEntryKey{}).(*accessEntry)
+	if entry == nil {
+		return
+	}
+	if ev.Route != nil {
+		entry.Route = ev.Route.Path
+	}
+	if len(ev.Params) > 0 {
+		entry.Params = make(map[string]string, len(ev.Params))
+		for _, p := range ev.Params {
+			entry.Params[p.Key] = p.Value
+		}
+	}
+	entry.Event = ev.Kind.String()
+	entry.Location = ev.Location
+}
+
+func (l *AccessLog) write(entry *accessEntry) {
+	var line []byte
+	if l.Format == JSONLogFormat {
+		var err error
+		if line, err = json.Marshal(entry); err != nil {
+			return
+		}
+	} else {
+		line = appendCommonLog(line, entry)
+		if l.Format == CombinedLogFormat {
+			line = append(line, ' ')
+			line = strconv.AppendQuote(line, entry.Referer)
+			line = append(line, ' ')
+			line = strconv.AppendQuote(line, entry.UserAgent)
+		}
+	}
+	line = append(line, '\n')
+
+	l.mu.Lock()
+	l.Out.Write(line)
+	l.mu.Unlock()
+}
+
+// appendCommonLog appends the entry in the Common Log Format, like
+//
+//	127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /a.gif HTTP/1.0" 200 2326
+func appendCommonLog(b []byte, entry *accessEntry) []byte {
+	b = append(b, entry.Remote...)
+	b = append(b, " - "...)
+	if entry.User != "" {
+		b = append(b, entry.User...)
+	} else {
+		b = append(b, '-')
+	}
+	b = append(b, " ["...)
+	b = entry.Time.AppendFormat(b, "02/Jan/2006:15:04:05 -0700")
+	b = append(b, "] "...)
+	b = strconv.AppendQuote(b, entry.Method+" "+entry.URI+" "+entry.Proto)
+	b = append(b, ' ')
+	b = strconv.AppendInt(b, int64(entry.Status), 10)
+	b = append(b, ' ')
+	if entry.Bytes > 0 {
+		b = strconv.AppendInt(b, entry.Bytes, 10)
+	} else {
+		b = append(b, '-')
+	}
+	return b
+}
@@ -0,0 +1,125 @@
+package httprouter
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func testAccessLog(format AccessLogFormat) (*bytes.Buffer, http.Handler) {
+	out := new(bytes.Buffer)
+	accessLog := NewAccessLog(out, format)
+
+	router := New()
+	router.Hooks = accessLog
+	router.GET("/users/:id", func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte("hello"))
+	})
+	router.GET("/dir/", func(w http.ResponseWriter, req *http.Request) {})
+	router.GET("/flush", func(w http.ResponseWriter, req *http.Request) {
+		w.(http.Flusher).Flush()
+	})
+	return out, accessLog.Middleware(router)
+}
+
+func TestAccessLogCommon(t *testing.T) {
+	out, handler := testAccessLog(CommonLogFormat)
+
+	req := httptest.NewRequest(http.MethodGet, "/users/42?x=1", nil)
+	req.SetBasicAuth("frank", "secret")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/users/42", nil))
+
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2: %q", len(lines), out.String())
+	}
+	date := `\[\d{2}/\w{3}/\d{4}:\d{2}:\d{2}:\d{2} [+-]\d{4}\]`
+	for i, pattern := range []string{
+		`^192\.0\.2\.1 - frank ` + date + ` "GET /users/42\?x=1 HTTP/1\.1" 200 5$`,
+		`^192\.0\.2\.1 - - ` + date + ` "POST /users/42 HTTP/1\.1" 405 \d+$`,
+	} {
+		if !regexp.MustCompile(pattern).MatchString(lines[i]) {
+			t.Errorf("line %q does not match %q", lines[i], pattern)
+		}
+	}
+}
+
+func TestAccessLogCombined(t *testing.T) {
+	out, handler := testAccessLog(CombinedLogFormat)
+
+	req := httptest.NewRequest(http.MethodGet, "/flush", nil)
+	req.Header.Set("Referer", "https://example.com/")
+	req.Header.Set("User-Agent", "test/1.0")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if !w.Flushed {
+		t.Error("flush was not passed on")
+	}
+
+	if !strings.HasSuffix(out.String(), `"GET /flush HTTP/1.1" 200 - "https://example.com/" "test/1.0"`+"\n") {
+		t.Errorf("unexpected line %q", out.String())
+	}
+}
+
+func TestAccessLogJSON(t *testing.T) {
+	out, handler := testAccessLog(JSONLogFormat)
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users/42", nil))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/dir?x=1", nil))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/missing", nil))
+
+	var entries []accessEntry
+	scanner := bufio.NewScanner(out)
+	for scanner.Scan() {
+		var entry accessEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("invalid line %q: %v", scanner.Text(), err)
+		}
+		entries = append(entries, entry)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("got %d entries, want 3", len(entries))
+	}
+
+	if e := entries[0]; e.Route != "/users/:id" || e.Params["id"] != "42" || e.Event != "matched" ||
+		e.Status != 200 || e.Bytes != 5 || e.Duration < 0 {
+		t.Errorf("unexpected entry for matched route %+v", e)
+	}
+	if e := entries[1]; e.URI != "/dir?x=1" || e.Event != "redirect_trailing_slash" ||
+		e.Location != "/dir/?x=1" || e.Status != http.StatusMovedPermanently {
+		t.Errorf("unexpected entry for redirect %+v", e)
+	}
+	if e := entries[2]; e.Route != "" || e.Event != "not_found" || e.Status != http.StatusNotFound {
+		t.Errorf("unexpected entry for missing route %+v", e)
+	}
+}
+
+type hijackRecorder struct {
+	*httptest.ResponseRecorder
+}
+
+func (h hijackRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return nil, nil, nil
+}
+
+func TestAccessLogHijack(t *testing.T) {
+	out := new(bytes.Buffer)
+	accessLog := NewAccessLog(out, CommonLogFormat)
+	handler := accessLog.Middleware(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if _, _, err := w.(http.Hijacker).Hijack(); err != nil {
+			t.Error(err)
+		}
+	}))
+	handler.ServeHTTP(hijackRecorder{httptest.NewRecorder()}, httptest.NewRequest(http.MethodGet, "/ws", nil))
+
+	if !strings.HasSuffix(out.String(), `"GET /ws HTTP/1.1" 101 -`+"\n") {
+		t.Errorf("unexpected line %q", out.String())
+	}
+}
@@ -0,0 +1,313 @@
+package httprouter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// Config is a declarative route table, usually loaded from a JSON file with
+// ParseConfig or LoadConfig.
+//
+//	{
+//	  "groups": {
+//	    "api": {"prefix": "/api", "middleware": ["auth"]}
+//	  },
+//	  "routes": [
+//	    {
+//	      "method": "GET",
+//	      "path": "/users/:id",
+//	      "handler": "getUser",
+//	      "group": "api",
+//	      "metadata": {"owner": "accounts"}
+//	    }
+//	  ]
+//	}
+type Config struct {
+	Groups map[string]*GroupConfig `json:"groups,omitempty"`
+	Routes []*RouteConfig          `json:"routes"`
+}
+
+// GroupConfig describes a group of routes of a Config.
+type GroupConfig struct {
+	// The path prefix of the group.
+	Prefix string `json:"prefix"`
+
+	// Metadata attached to every route of the group.
+	Metadata Metadata `json:"metadata,omitempty"`
+
+	// Names of the middleware wrapping every route of the group, outermost
+	// first. They wrap the middleware of the routes.
+	Middleware []string `json:"middleware,omitempty"`
+}
+
+// RouteConfig describes a single route of a Config.
+type RouteConfig struct {
+	Method string `json:"method"`
+
+	// The path of the route, relative to the prefix of its group if any.
+	Path string `json:"path"`
+
+	// The name of the handler in the Registry.
+	Handler string `json:"handler"`
+
+	// The name of the group of the route, if any.
+	Group string `json:"group,omitempty"`
+
+	// Metadata attached to the route. It overrides the metadata of the group.
+	Metadata Metadata `json:"metadata,omitempty"`
+
+	// Names of the middleware wrapping the handler, outermost first.
+	Middleware []string `json:"middleware,omitempty"`
+
+	// The line of the route in the file it was parsed from, or 0 if unknown.
+	Line int `json:"-"`
+}
+
+// Registry resolves the names used in a Config.
+type Registry struct {
+	Handlers   map[string]http.Handler
+	Middleware map[string]func(http.Handler) http.Handler
+}
+
+// ConfigError describes an invalid route of a Config.
+type ConfigError struct {
+	Line   int
+	Method string
+	Path   string
+	Err    error
+}
+
+func (e *ConfigError) Error() string {
+	msg := fmt.Sprintf("%s %s: %v", e.Method, e.Path, e.Err)
+	if e.Line > 0 {
+		msg = fmt.Sprintf("line %d: %s", e.Line, msg)
+	}
+	return msg
+}
+
+// ConfigErrors is the error returned for a Config with invalid routes. It lists
+// every invalid route in the order of the routes.
+type ConfigErrors []*ConfigError
+
+func (errs ConfigErrors) Error() string {
+	msgs := make([]string, len(errs))
+	for i, err := range errs {
+		msgs[i] = err.Error()
+	}
+	return "config: " + strings.Join(msgs, "; ")
+}
+
+// ParseConfig parses a route table in JSON. The line of every route is
+// recorded for error reporting.
+func ParseConfig(data []byte) (*Config, error) {
+	c := new(Config)
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, fmt.Errorf("config: %v", err)
+	}
+	lines := jsonArrayLines(data, "routes")
+	for i, rc := range c.Routes {
+		if rc == nil {
+			return nil, fmt.Errorf("config: route %d is empty", i)
+		}
+		if i < len(lines) {
+			rc.Line = lines[i]
+		}
+	}
+	return c, nil
+}
+
+// LoadConfig reads a route table in JSON from the given file, see
+// ParseConfig.
+func LoadConfig(filename string) (*Config, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	c, err := ParseConfig(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	return c, nil
+}
+
+// Apply registers the routes of c on r, resolving handler and middleware names
+// with reg.
+//
+// Every route is validated before any is registered: unknown names, malformed
+// paths and conflicts with registered routes or other routes of c are all
+// reported in a ConfigErrors. In that case r is left unchanged.
+func (c *Config) Apply(r *Router, reg *Registry) error {
+	if reg == nil {
+		reg = &Registry{}
+	}
+
+	var errs ConfigErrors
+	scratch := New()
+	handles := make([]http.Handler, len(c.Routes))
+	paths := make([]string, len(c.Routes))
+
+	for i, rc := range c.Routes {
+		fail := func(format string, args ...interface{}) {
+			errs = append(errs, &ConfigError{Line: rc.Line, Method: rc.Method, Path: rc.Path, Err: fmt.Errorf(format, args...)})
+		}
+
+		handle := reg.Handlers[rc.Handler]
+		if handle == nil {
+			fail("unknown handler %q", rc.Handler)
+		}
+
+		path, err := c.fullPath(rc)
+		if err != nil {
+			fail("%v", err)
+			continue
+		}
+		middleware := rc.Middleware
+		if rc.Group != "" {
+			middleware = append(append([]string(nil), c.Groups[rc.Group].Middleware...), middleware...)
+		}
+
+		for j := len(middleware) - 1; j >= 0; j-- {
+			mw := reg.Middleware[middleware[j]]
+			if mw == nil {
+				fail("unknown middleware %q", middleware[j])
+				handle = nil
+				continue
+			}
+			if handle != nil {
+				handle = mw(handle)
+			}
+		}
+
+		if rc.Group != "" {
+			if err := checkRateLimit(c.Groups[rc.Group].Metadata); err != nil {
+				fail("%v", err)
+			}
+		}
+		if err := checkRateLimit(rc.Metadata); err != nil {
+			fail("%v", err)
+		}
+
+		if err := r.CheckRoute(rc.Method, path); err != nil {
+			fail("%v", err)
+			continue
+		}
+		if err := scratch.tryHandler(rc.Method, path, http.NotFoundHandler()); err != nil {
+			fail("%v", err)
+			continue
+		}
+		handles[i], paths[i] = handle, path
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+
+	for i, rc := range c.Routes {
+		var opts []RouteOption
+		if rc.Group != "" {
+			opts = append(opts, metadataOption(c.Groups[rc.Group].Metadata))
+		}
+		opts = append(opts, metadataOption(rc.Metadata))
+		r.Handler(rc.Method, paths[i], handles[i], opts...)
+	}
+	return nil
+}
+
+// fullPath returns the path of rc including the prefix of its group.
+func (c *Config) fullPath(rc *RouteConfig) (string, error) {
+	if rc.Group == "" {
+		return rc.Path, nil
+	}
+	g := c.Groups[rc.Group]
+	if g == nil {
+		return "", fmt.Errorf("unknown group %q", rc.Group)
+	}
+	if len(rc.Path) < 1 || rc.Path[0] != '/' {
+		return "", errors.New("path must begin with '/' in path '" + rc.Path + "'")
+	}
+	return strings.TrimSuffix(g.Prefix, "/") + rc.Path, nil
+}
+
+// metadataOption returns an option attaching all of m to a route, in a stable
+// order.
+func metadataOption(m Metadata) RouteOption {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return func(rt *Route) {
+		for _, key := range keys {
+			WithMetadata(key, m[key])(rt)
+		}
+	}
+}
+
+// jsonArrayLines returns the lines on which the elements of the array stored
+// under the given key of the top level object in data begin.
+func jsonArrayLines(data []byte, key string) []int {
+	var lines []int
+	line, depth := 1, 0
+	inString, escaped := false, false
+	inArray := false
+	var str []byte
+	pending, lastKey := "", ""
+	expectElem := false
+
+	for _, c := range data {
+		if c == '\n' {
+			line++
+		}
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+				if depth == 1 {
+					pending = string(str)
+				}
+			default:
+				str = append(str, c)
+			}
+			continue
+		}
+
+		if expectElem && c != ' ' && c != '\t' && c != '\r' && c != '\n' && c != ']' {
+			lines = append(lines, line)
+			expectElem = false
+		}
+		switch c {
+		case '"':
+			inString, str = true, str[:0]
+		case '{', '[':
+			depth++
+			if c == '[' && depth == 2 && lastKey == key {
+				inArray, expectElem = true, true
+			}
+		case '}', ']':
+			depth--
+			if depth == 1 {
+				inArray = false
+			}
+		case ':':
+			if depth == 1 {
+				lastKey = pending
+			}
+		case ',':
+			if inArray && depth == 2 {
+				expectElem = true
+			}
+			if depth == 1 {
+				lastKey = ""
+			}
+		}
+	}
+	return lines
+}
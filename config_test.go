@@ -0,0 +1,143 @@
+package httprouter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func testRegistry(trace *[]string) *Registry {
+	handler := func(name string) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			*trace = append(*trace, name)
+		})
+	}
+	middleware := func(name string) func(http.Handler) http.Handler {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				*trace = append(*trace, name)
+				next.ServeHTTP(w, req)
+			})
+		}
+	}
+	return &Registry{
+		Handlers: map[string]http.Handler{
+			"index": handler("index"),
+			"user":  handler("user"),
+		},
+		Middleware: map[string]func(http.Handler) http.Handler{
+			"auth":  middleware("auth"),
+			"trace": middleware("trace"),
+		},
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	c, err := LoadConfig("testdata/routes.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := []int{c.Routes[0].Line, c.Routes[1].Line, c.Routes[2].Line}; !reflect.DeepEqual(lines, []int{10, 11, 19}) {
+		t.Errorf("unexpected route lines %v", lines)
+	}
+
+	var trace []string
+	router := New()
+	if err := c.Apply(router, testRegistry(&trace)); err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/users/42", nil))
+	if want := []string{"auth", "trace", "user"}; !reflect.DeepEqual(trace, want) {
+		t.Errorf("unexpected call order %v, want %v", trace, want)
+	}
+
+	route, _, _ := router.Lookup(http.MethodGet, "/api/users/42")
+	if route == nil {
+		t.Fatal("route not found")
+	}
+	if owner := route.Value("owner"); owner != "accounts" {
+		t.Errorf("route metadata does not override group metadata: owner is %v", owner)
+	}
+	route, _, _ = router.Lookup(http.MethodPost, "/api/users")
+	if owner := route.Value("owner"); owner != "platform" {
+		t.Errorf("group metadata missing: owner is %v", owner)
+	}
+}
+
+func TestParseConfigJSON(t *testing.T) {
+	data := `{
+  "groups": {"api": {"prefix": "/api"}},
+  "routes": [
+    {"method": "GET", "path": "/", "handler": "index"},
+    {
+      "method": "GET",
+      "path": "/users/[",
+      "handler": "user"
+    }, {"method": "GET", "path": "/x", "handler": "index"}
+  ]
+}`
+	c, err := ParseConfig([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var lines []int
+	for _, rc := range c.Routes {
+		lines = append(lines, rc.Line)
+	}
+	if want := []int{4, 5, 9}; !reflect.DeepEqual(lines, want) {
+		t.Errorf("unexpected route lines %v, want %v", lines, want)
+	}
+
+	if _, err := ParseConfig([]byte("routes: [a: b: c]")); err == nil {
+		t.Error("no error for a config not in JSON")
+	}
+}
+
+func TestConfigApplyErrors(t *testing.T) {
+	data := `{
+  "groups": {"api": {"prefix": "/api"}},
+  "routes": [
+    {"method": "GET", "path": "/users/:id", "handler": "user"},
+    {"method": "GET", "path": "/users/:name", "handler": "user"},
+    {"method": "GET", "path": "/files/*path/x", "handler": "index"},
+    {"method": "GET", "path": "/a", "handler": "missing"},
+    {"method": "GET", "path": "/b", "handler": "index", "middleware": ["missing"]},
+    {"method": "GET", "path": "/c", "handler": "index", "group": "missing"},
+    {"method": "GET", "path": "/d", "handler": "index", "group": "api"},
+    {"method": "GET", "path": "/taken", "handler": "index"},
+    {"method": "GET", "path": "/e", "handler": "index", "metadata": {"httprouter.rateLimit": "5/fortnight"}}
+  ]
+}`
+	c, err := ParseConfig([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var trace []string
+	router := New()
+	router.GET("/taken", func(http.ResponseWriter, *http.Request) {})
+	err = c.Apply(router, testRegistry(&trace))
+
+	errs, ok := err.(ConfigErrors)
+	if !ok {
+		t.Fatalf("expected ConfigErrors, got %v", err)
+	}
+	var lines []int
+	for _, e := range errs {
+		lines = append(lines, e.Line)
+	}
+	if want := []int{5, 6, 7, 8, 9, 11, 12}; !reflect.DeepEqual(lines, want) {
+		t.Errorf("unexpected error lines %v, want %v\n%v", lines, want, err)
+	}
+	if !strings.Contains(err.Error(), "line 7: GET /a: unknown handler \"missing\"") {
+		t.Errorf("unexpected error message %q", err)
+	}
+
+	if route, _, _ := router.Lookup(http.MethodGet, "/users/1"); route != nil {
+		t.Error("routes were registered although the config is invalid")
+	}
+}
@@ -0,0 +1,269 @@
+package httprouter
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORS is a Cross-Origin Resource Sharing policy.
+//
+// Once set on a Router or Group, the router adds the CORS headers to every
+// response to an allowed origin, including its automatic 404 and 405
+// responses. Preflight requests are answered by the automatic OPTIONS replies
+// (see Router.HandleOPTIONS), which allow exactly the methods registered for
+// the requested path.
+type CORS struct {
+	// Origins which are allowed to make cross-origin requests.
+	// An entry is either an exact origin like "https://example.com", a pattern
+	// with a single wildcard like "https://*.example.com", or "*" which allows
+	// any origin. "*" is ignored if AllowCredentials is enabled.
+	AllowedOrigins []string
+
+	// An optional function deciding whether an origin is allowed.
+	// It is only called if the origin doesn't match AllowedOrigins.
+	AllowOriginFunc func(origin string) bool
+
+	// Request headers which are allowed in cross-origin requests.
+	// If empty, the headers requested by a preflight are allowed.
+	AllowedHeaders []string
+
+	// Response headers which are exposed to the client.
+	ExposedHeaders []string
+
+	// If enabled, responses may be shared with requests including credentials
+	// like cookies. The allowed origin is then echoed, and browsers forbid
+	// any origin to be allowed, so "*" in AllowedOrigins is ignored.
+	AllowCredentials bool
+
+	// How long the result of a preflight request may be cached by the client.
+	// If zero, no Access-Control-Max-Age header is sent.
+	MaxAge time.Duration
+}
+
+// AllowOrigin reports whether cross-origin requests from the given origin are
+// allowed by the policy.
+func (c *CORS) AllowOrigin(origin string) bool {
+	for _, allowed := range c.AllowedOrigins {
+		if allowed == "*" {
+			if c.AllowCredentials {
+				continue
+			}
+			return true
+		}
+		if allowed == origin {
+			return true
+		}
+		if i := strings.IndexByte(allowed, '*'); i >= 0 {
+			prefix, suffix := allowed[:i], allowed[i+1:]
+			if len(origin) > len(prefix)+len(suffix) &&
+				strings.HasPrefix(origin, prefix) && strings.HasSuffix(origin, suffix) {
+				return true
+			}
+		}
+	}
+	return c.AllowOriginFunc != nil && c.AllowOriginFunc(origin)
+}
+
+func (c *CORS) allowsAnyOrigin() bool {
+	for _, allowed := range c.AllowedOrigins {
+		if allowed == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// setHeaders adds the headers common to preflight and actual responses.
+// It reports whether the origin of the request is allowed.
+func (c *CORS) setHeaders(header http.Header, origin string) bool {
+	header.Add("Vary", "Origin")
+	if origin == "" || !c.AllowOrigin(origin) {
+		return false
+	}
+
+	if c.allowsAnyOrigin() && !c.AllowCredentials {
+		header.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		header.Set("Access-Control-Allow-Origin", origin)
+	}
+	if c.AllowCredentials {
+		header.Set("Access-Control-Allow-Credentials", "true")
+	}
+	return true
+}
+
+// handleActual adds the CORS headers of an actual (non-preflight) response.
+func (c *CORS) handleActual(w http.ResponseWriter, req *http.Request) {
+	header := w.Header()
+	if c.setHeaders(header, req.Header.Get("Origin")) && len(c.ExposedHeaders) > 0 {
+		header.Set("Access-Control-Expose-Headers", strings.Join(c.ExposedHeaders, ", "))
+	}
+}
+
+// handlePreflight adds the CORS headers of a response to a preflight request
+// for a path which allows the given methods.
+func (c *CORS) handlePreflight(w http.ResponseWriter, req *http.Request, allow string) {
+	header := w.Header()
+	header.Add("Vary", "Access-Control-Request-Method")
+	header.Add("Vary", "Access-Control-Request-Headers")
+
+	origin := req.Header.Get("Origin")
+	if origin == "" || !c.AllowOrigin(origin) {
+		header.Add("Vary", "Origin")
+		return
+	}
+
+	method := req.Header.Get("Access-Control-Request-Method")
+	if !containsMethod(allow, method) {
+		header.Add("Vary", "Origin")
+		return
+	}
+
+	requested := req.Header.Get("Access-Control-Request-Headers")
+	if len(c.AllowedHeaders) > 0 {
+		for _, h := range strings.Split(requested, ",") {
+			if h = strings.TrimSpace(h); h != "" && !containsToken(strings.Join(c.AllowedHeaders, ","), h) {
+				header.Add("Vary", "Origin")
+				return
+			}
+		}
+	}
+
+	c.setHeaders(header, origin)
+	header.Set("Access-Control-Allow-Methods", allow)
+	if len(c.AllowedHeaders) > 0 {
+		header.Set("Access-Control-Allow-Headers", strings.Join(c.AllowedHeaders, ", "))
+	} else if requested != "" {
+		header.Set("Access-Control-Allow-Headers", requested)
+	}
+	if c.MaxAge > 0 {
+		header.Set("Access-Control-Max-Age", strconv.Itoa(int(c.MaxAge/time.Second)))
+	}
+}
+
+// isPreflight reports whether req is a CORS preflight request.
+func isPreflight(req *http.Request) bool {
+	return req.Method == http.MethodOptions &&
+		req.Header.Get("Origin") != "" &&
+		req.Header.Get("Access-Control-Request-Method") != ""
+}
+
+// containsMethod reports whether the list of allowed methods contains the
+// given method.
+func containsMethod(allow, method string) bool {
+	for _, m := range strings.Split(allow, ", ") {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+// containsToken reports whether the comma separated list contains the given
+// token, ignoring case.
+func containsToken(list, token string) bool {
+	for _, t := range strings.Split(list, ",") {
+		if strings.EqualFold(strings.TrimSpace(t), token) {
+			return true
+		}
+	}
+	return false
+}
+
+// corsPolicy returns the CORS policy applying to the given request path, if
+// any. The policy of the innermost group containing the path wins over the
+// policy of the router.
+func (r *Router) corsPolicy(path string) *CORS {
+	if r.groups == nil || len(path) == 0 || path[0] != '/' {
+		return r.CORS
+	}
+	if g := r.groups.innermost(path, nil); g != nil {
+		return g.CORS
+	}
+	return r.CORS
+}
+
+// groupIndex indexes the groups of a router by the segments of their
+// prefixes, so that the groups containing a path are found without scanning
+// all of them.
+type groupIndex struct {
+	groups   []*Group // the groups with the prefix of the node
+	literals map[string]*groupIndex
+	params   []*groupParam
+}
+
+// groupParam is a segment of a prefix with a named parameter, like ":id" or
+// "v:version".
+type groupParam struct {
+	prefix string // the literal before the parameter
+	next   *groupIndex
+}
+
+// insert adds a group to the index.
+func (ix *groupIndex) insert(g *Group) {
+	prefix := g.prefix
+	for len(prefix) > 0 {
+		segment := prefix[1:]
+		prefix = ""
+		if end := strings.IndexByte(segment, '/'); end >= 0 {
+			segment, prefix = segment[:end], segment[end:]
+		}
+
+		if colon := strings.IndexByte(segment, ':'); colon >= 0 {
+			var next *groupIndex
+			for _, p := range ix.params {
+				if p.prefix == segment[:colon] {
+					next = p.next
+				}
+			}
+			if next == nil {
+				next = new(groupIndex)
+				ix.params = append(ix.params, &groupParam{prefix: segment[:colon], next: next})
+			}
+			ix = next
+			continue
+		}
+
+		next := ix.literals[segment]
+		if next == nil {
+			if ix.literals == nil {
+				ix.literals = make(map[string]*groupIndex)
+			}
+			next = new(groupIndex)
+			ix.literals[segment] = next
+		}
+		ix = next
+	}
+	ix.groups = append(ix.groups, g)
+}
+
+// innermost returns the group with the longest prefix containing path and a
+// CORS policy, or best if there is none longer. Named parameters in the
+// prefixes match any single non-empty path segment, and a prefix ends at a
+// segment boundary of the path.
+func (ix *groupIndex) innermost(path string, best *Group) *Group {
+	for _, g := range ix.groups {
+		if g.CORS != nil && (best == nil || len(g.prefix) > len(best.prefix)) {
+			best = g
+		}
+	}
+	if len(path) == 0 {
+		return best
+	}
+
+	segment, rest := path[1:], ""
+	if end := strings.IndexByte(segment, '/'); end >= 0 {
+		segment, rest = segment[:end], segment[end:]
+	}
+	if next := ix.literals[segment]; next != nil {
+		best = next.innermost(rest, best)
+	}
+	for _, p := range ix.params {
+		if len(segment) > len(p.prefix) && strings.HasPrefix(segment, p.prefix) {
+			best = p.next.innermost(rest, best)
+		}
+	}
+	return best
+}
@@ -0,0 +1,278 @@
+package httprouter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCORSAllowOrigin(t *testing.T) {
+	policy := &CORS{
+		AllowedOrigins: []string{"https://example.com", "https://*.example.org"},
+		AllowOriginFunc: func(origin string) bool {
+			return origin == "https://func.example.net"
+		},
+	}
+
+	tests := []struct {
+		origin  string
+		allowed bool
+	}{
+		{"https://example.com", true},
+		{"http://example.com", false},
+		{"https://api.example.org", true},
+		{"https://a.b.example.org", true},
+		{"https://example.org", false},
+		{"https://.example.org", false},
+		{"https://evil.com", false},
+		{"https://func.example.net", true},
+	}
+	for _, tt := range tests {
+		if got := policy.AllowOrigin(tt.origin); got != tt.allowed {
+			t.Errorf("AllowOrigin(%q) = %t, want %t", tt.origin, got, tt.allowed)
+		}
+	}
+}
+
+func TestRouterCORSAnyOriginCredentials(t *testing.T) {
+	router := New()
+	router.CORS = &CORS{
+		AllowedOrigins:   []string{"*", "https://example.com"},
+		AllowCredentials: true,
+	}
+	router.GET("/path", func(_ http.ResponseWriter, _ *http.Request) {})
+
+	// any origin may not make credentialed requests
+	tests := []struct {
+		origin, allowOrigin, allowCredentials string
+	}{
+		{"https://evil.com", "", ""},
+		{"https://example.com", "https://example.com", "true"},
+	}
+	for _, tt := range tests {
+		r, _ := http.NewRequest(http.MethodGet, "/path", nil)
+		r.Header.Set("Origin", tt.origin)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		header := w.Header()
+		if header.Get("Access-Control-Allow-Origin") != tt.allowOrigin || header.Get("Access-Control-Allow-Credentials") != tt.allowCredentials {
+			t.Errorf("%s: unexpected headers %v", tt.origin, header)
+		}
+	}
+}
+
+func TestRouterCORSPreflight(t *testing.T) {
+	handlerFunc := func(_ http.ResponseWriter, _ *http.Request) {}
+
+	router := New()
+	router.CORS = &CORS{
+		AllowedOrigins:   []string{"https://example.com"},
+		AllowCredentials: true,
+		MaxAge:           10 * time.Minute,
+	}
+	router.GET("/path", handlerFunc)
+	router.PUT("/path", handlerFunc)
+
+	preflight := func(origin, method string) *httptest.ResponseRecorder {
+		r, _ := http.NewRequest(http.MethodOptions, "/path", nil)
+		r.Header.Set("Origin", origin)
+		r.Header.Set("Access-Control-Request-Method", method)
+		r.Header.Set("Access-Control-Request-Headers", "X-Token")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		return w
+	}
+
+	w := preflight("https://example.com", http.MethodPut)
+	if w.Code != http.StatusNoContent {
+		t.Errorf("unexpected preflight status %d", w.Code)
+	}
+	header := w.Header()
+	if got := header.Get("Access-Control-Allow-Origin"); got != "https://example.com" {
+		t.Errorf("unexpected Access-Control-Allow-Origin %q", got)
+	}
+	if got := header.Get("Access-Control-Allow-Methods"); got != "GET, OPTIONS, PUT" {
+		t.Errorf("unexpected Access-Control-Allow-Methods %q", got)
+	}
+	if got := header.Get("Access-Control-Allow-Headers"); got != "X-Token" {
+		t.Errorf("unexpected Access-Control-Allow-Headers %q", got)
+	}
+	if got := header.Get("Access-Control-Allow-Credentials"); got != "true" {
+		t.Errorf("unexpected Access-Control-Allow-Credentials %q", got)
+	}
+	if got := header.Get("Access-Control-Max-Age"); got != "600" {
+		t.Errorf("unexpected Access-Control-Max-Age %q", got)
+	}
+	if got := header.Get("Allow"); got != "GET, OPTIONS, PUT" {
+		t.Errorf("unexpected Allow %q", got)
+	}
+
+	// method not registered for the path
+	w = preflight("https://example.com", http.MethodDelete)
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("unexpected Access-Control-Allow-Origin %q for disallowed method", got)
+	}
+
+	// origin not allowed
+	w = preflight("https://evil.com", http.MethodPut)
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("unexpected Access-Control-Allow-Origin %q for disallowed origin", got)
+	}
+
+	// headers not allowed
+	router.CORS.AllowedHeaders = []string{"Content-Type"}
+	w = preflight("https://example.com", http.MethodPut)
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("unexpected Access-Control-Allow-Origin %q for disallowed headers", got)
+	}
+}
+
+func TestRouterCORSActual(t *testing.T) {
+	router := New()
+	router.CORS = &CORS{
+		AllowedOrigins: []string{"*"},
+		ExposedHeaders: []string{"X-Request-Id"},
+	}
+	router.POST("/path", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	})
+
+	testRoutes := []struct {
+		method string
+		path   string
+		code   int
+	}{
+		{http.MethodPost, "/path", http.StatusCreated},
+		{http.MethodGet, "/path", http.StatusMethodNotAllowed},
+		{http.MethodGet, "/nope", http.StatusNotFound},
+	}
+	for _, tr := range testRoutes {
+		r, _ := http.NewRequest(tr.method, tr.path, nil)
+		r.Header.Set("Origin", "https://example.com")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if w.Code != tr.code {
+			t.Errorf("%s %s: unexpected status %d", tr.method, tr.path, w.Code)
+		}
+		if got := w.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+			t.Errorf("%s %s: unexpected Access-Control-Allow-Origin %q", tr.method, tr.path, got)
+		}
+		if got := w.Header().Get("Access-Control-Expose-Headers"); got != "X-Request-Id" {
+			t.Errorf("%s %s: unexpected Access-Control-Expose-Headers %q", tr.method, tr.path, got)
+		}
+		if got := w.Header().Get("Vary"); got != "Origin" {
+			t.Errorf("%s %s: unexpected Vary %q", tr.method, tr.path, got)
+		}
+	}
+
+	// no Origin, no CORS headers
+	r, _ := http.NewRequest(http.MethodPost, "/path", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("unexpected Access-Control-Allow-Origin %q without Origin", got)
+	}
+}
+
+func TestRouterCORSGroupOverride(t *testing.T) {
+	handlerFunc := func(_ http.ResponseWriter, _ *http.Request) {}
+
+	router := New()
+	router.CORS = &CORS{AllowedOrigins: []string{"https://example.com"}}
+	router.GET("/public", handlerFunc)
+
+	admin := router.Group("/admin")
+	admin.CORS = &CORS{AllowedOrigins: []string{"https://admin.example.com"}}
+	admin.GET("/users", handlerFunc)
+
+	testRoutes := []struct {
+		path   string
+		origin string
+		allow  string
+	}{
+		{"/public", "https://example.com", "https://example.com"},
+		{"/public", "https://admin.example.com", ""},
+		{"/admin/users", "https://example.com", ""},
+		{"/admin/users", "https://admin.example.com", "https://admin.example.com"},
+		{"/admin/nope", "https://admin.example.com", "https://admin.example.com"},
+	}
+	for _, tr := range testRoutes {
+		r, _ := http.NewRequest(http.MethodGet, tr.path, nil)
+		r.Header.Set("Origin", tr.origin)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if got := w.Header().Get("Access-Control-Allow-Origin"); got != tr.allow {
+			t.Errorf("%s from %s: unexpected Access-Control-Allow-Origin %q", tr.path, tr.origin, got)
+		}
+	}
+}
+
+func TestRouterCORSNestedGroups(t *testing.T) {
+	handlerFunc := func(_ http.ResponseWriter, _ *http.Request) {}
+
+	router := New()
+	router.CORS = &CORS{AllowedOrigins: []string{"https://example.com"}}
+	api := router.Group("/api")
+	tenant := api.Group("/:tenant")
+	tenant.CORS = &CORS{AllowedOrigins: []string{"https://tenant.example.com"}}
+	versioned := tenant.Group("/v:version")
+	versioned.CORS = &CORS{AllowedOrigins: []string{"https://v.example.com"}}
+	router.Group("/api/acme/admin").CORS = &CORS{AllowedOrigins: []string{"https://admin.example.com"}}
+	router.GET("/*path", handlerFunc)
+
+	testRoutes := []struct {
+		path   string
+		policy string
+	}{
+		{"/", "https://example.com"},
+		{"/api", "https://example.com"},
+		{"/api/", "https://example.com"},
+		{"/api/acme", "https://tenant.example.com"},
+		{"/api/acme/users", "https://tenant.example.com"},
+		{"/api/acme/v", "https://tenant.example.com"},
+		{"/api/acme/v2", "https://v.example.com"},
+		{"/api/acme/v2/users", "https://v.example.com"},
+		{"/api/acme/admin", "https://admin.example.com"},
+		{"/api/acme/admin/v2", "https://admin.example.com"},
+		{"/api/other/admin", "https://tenant.example.com"},
+		{"/apis/acme", "https://example.com"},
+	}
+	for _, tr := range testRoutes {
+		r, _ := http.NewRequest(http.MethodGet, tr.path, nil)
+		r.Header.Set("Origin", tr.policy)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if got := w.Header().Get("Access-Control-Allow-Origin"); got != tr.policy {
+			t.Errorf("%s: unexpected Access-Control-Allow-Origin %q", tr.path, got)
+		}
+	}
+}
+
+func TestRouterCORSPreflightRedirect(t *testing.T) {
+	handlerFunc := func(_ http.ResponseWriter, _ *http.Request) {}
+
+	router := New()
+	router.RedirectFixedPath = true
+	router.CORS = &CORS{AllowedOrigins: []string{"https://example.com"}}
+	router.PUT("/path", handlerFunc)
+	router.PUT("/dir/", handlerFunc)
+	router.PUT("/custom", handlerFunc)
+	router.OPTIONS("/custom", func(_ http.ResponseWriter, _ *http.Request) {
+		t.Error("OPTIONS handler called")
+	})
+
+	for _, path := range []string{"/path", "/path/", "/dir", "/../path", "/./dir", "/custom/", "/./custom"} {
+		r, _ := http.NewRequest(http.MethodOptions, path, nil)
+		r.Header.Set("Origin", "https://example.com")
+		r.Header.Set("Access-Control-Request-Method", http.MethodPut)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if w.Code != http.StatusNoContent || w.Header().Get("Location") != "" {
+			t.Errorf("%s: unexpected preflight status %d to %q", path, w.Code, w.Header().Get("Location"))
+		}
+		if got := w.Header().Get("Access-Control-Allow-Methods"); got != "OPTIONS, PUT" {
+			t.Errorf("%s: unexpected Access-Control-Allow-Methods %q", path, got)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package httprouter
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Errors of requests rejected by a PathEncoding policy.
+var (
+	ErrInvalidEscape  = errors.New("httprouter: invalid escape in path")
+	ErrDoubleEncoding = errors.New("httprouter: double encoded character in path")
+	ErrEncodedSlash   = errors.New("httprouter: encoded slash in path")
+)
+
+// EncodedSlashes selects how a PathEncoding policy treats encoded slashes
+// (%2F) in request paths.
+type EncodedSlashes int
+
+const (
+	// Encoded slashes are kept. With UseRawPath they don't separate path
+	// segments, so they can be part of parameter values.
+	KeepEncodedSlashes EncodedSlashes = iota
+
+	// Encoded slashes are decoded, so they separate path segments like any
+	// other slash.
+	DecodeEncodedSlashes
+
+	// Requests with encoded slashes are rejected with 400 (Bad Request).
+	RejectEncodedSlashes
+)
+
+// PathEncoding is a policy for the escapes in request paths, enforced by the
+// router before looking up the route of a request. Requests with invalid
+// escapes, like "%zz", are always rejected with 400 (Bad Request), and the
+// hex digits of escapes are normalized to upper case, so that "%2f" and "%2F"
+// are matched alike.
+type PathEncoding struct {
+	EncodedSlashes EncodedSlashes
+
+	// If set, double encoded characters like "%252F" are accepted. They are
+	// rejected by default, since intermediaries which decode paths once more
+	// would see a different path than the router.
+	AllowDoubleEncoding bool
+}
+
+// apply enforces the policy on u. It returns u itself if the path is
+// unchanged, otherwise a normalized copy.
+func (p *PathEncoding) apply(u *url.URL) (*url.URL, error) {
+	escaped := u.RawPath
+	if escaped == "" {
+		escaped = u.EscapedPath()
+	}
+
+	var b *strings.Builder // the normalized path, once it differs
+	for i := 0; i < len(escaped); i++ {
+		if escaped[i] != '%' {
+			if b != nil {
+				b.WriteByte(escaped[i])
+			}
+			continue
+		}
+
+		if i+2 >= len(escaped) || !isHex(escaped[i+1]) || !isHex(escaped[i+2]) {
+			return nil, ErrInvalidEscape
+		}
+		c := unhex(escaped[i+1])<<4 | unhex(escaped[i+2])
+
+		replacement := strings.ToUpper(escaped[i : i+3])
+		if c == '/' {
+			switch p.EncodedSlashes {
+			case RejectEncodedSlashes:
+				return nil, ErrEncodedSlash
+			case DecodeEncodedSlashes:
+				replacement = "/"
+			}
+		}
+		if b == nil && replacement != escaped[i:i+3] {
+			b = new(strings.Builder)
+			b.WriteString(escaped[:i])
+		}
+		if b != nil {
+			b.WriteString(replacement)
+		}
+		i += 2
+	}
+	if !p.AllowDoubleEncoding && hasEscape(escaped) {
+		return nil, ErrDoubleEncoding
+	}
+	if b == nil {
+		return u, nil
+	}
+
+	normalized := *u
+	normalized.RawPath = b.String()
+	if unescaped, err := url.PathUnescape(normalized.RawPath); err == nil {
+		normalized.Path = unescaped
+	}
+	if (&url.URL{Path: normalized.Path}).EscapedPath() == normalized.RawPath {
+		// the default encoding is the same
+		normalized.RawPath = ""
+	}
+	return &normalized, nil
+}
+
+// hasEscape reports whether the valid escaped path contains escapes once
+// unescaped, like "%252F" or "%25%32%46".
+func hasEscape(escaped string) bool {
+	if strings.IndexByte(escaped, '%') < 0 {
+		return false
+	}
+	unescaped, err := url.PathUnescape(escaped)
+	if err != nil {
+		return false
+	}
+	for i := strings.IndexByte(unescaped, '%'); i >= 0; i = strings.IndexByte(unescaped, '%') {
+		if i+2 < len(unescaped) && isHex(unescaped[i+1]) && isHex(unescaped[i+2]) {
+			return true
+		}
+		unescaped = unescaped[i+1:]
+	}
+	return false
+}
+
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+func unhex(c byte) byte {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0'
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10
+	default:
+		return c - 'A' + 10
+	}
+}
+
+// reject answers a request rejected before calling the handle of its route.
+func (r *Router) reject(w http.ResponseWriter, req *http.Request, code int, err error, d *dispatch) {
+	d.outcome = OutcomeRejected
+	r.emit(Event{Kind: EventRejected, Request: req, Status: code, Err: err})
+
+	detail := strings.TrimPrefix(err.Error(), "httprouter: ")
+	if r.ErrorRenderer != nil {
+		p := newProblem(req, code)
+		p.Detail = detail
+		r.ErrorRenderer(w, req, p)
+	} else {
+		http.Error(w, http.StatusText(code)+": "+detail, code)
+	}
+}
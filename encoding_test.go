@@ -0,0 +1,135 @@
+package httprouter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRouterPathEncoding(t *testing.T) {
+	tests := []struct {
+		policy  PathEncoding
+		rawPath string // set on the request as is
+		code    int
+		route   string
+		name    string
+	}{
+		{PathEncoding{}, "/files/a%2Fb", http.StatusOK, "/files/:name", "a%2Fb"},
+		{PathEncoding{}, "/files/a%2fb", http.StatusOK, "/files/:name", "a%2Fb"},
+		{PathEncoding{}, "/files/a%2f%c3%a9", http.StatusOK, "/files/:name", "a%2F%C3%A9"},
+		{PathEncoding{}, "/files/100%25", http.StatusOK, "/files/:name", "100%25"},
+		{PathEncoding{}, "/files/100%25x", http.StatusOK, "/files/:name", "100%25x"},
+		// literals match escaped slashes, like without a policy
+		{PathEncoding{}, "/a%2Fb", http.StatusOK, "/a/b", ""},
+		{PathEncoding{EncodedSlashes: DecodeEncodedSlashes}, "/a%2Fb", http.StatusOK, "/a/b", ""},
+		{PathEncoding{EncodedSlashes: DecodeEncodedSlashes}, "/a%2fb", http.StatusOK, "/a/b", ""},
+		{PathEncoding{EncodedSlashes: DecodeEncodedSlashes}, "/files/a%2Fb%20c", http.StatusNotFound, "", ""},
+		{PathEncoding{EncodedSlashes: RejectEncodedSlashes}, "/files/a%2Fb", http.StatusBadRequest, "", ""},
+		{PathEncoding{EncodedSlashes: RejectEncodedSlashes}, "/files/a%2fb", http.StatusBadRequest, "", ""},
+		{PathEncoding{EncodedSlashes: RejectEncodedSlashes}, "/files/a%20b", http.StatusOK, "/files/:name", "a%20b"},
+		{PathEncoding{}, "/files/a%252F", http.StatusBadRequest, "", ""},
+		{PathEncoding{}, "/files/a%2541", http.StatusBadRequest, "", ""},
+		{PathEncoding{}, "/files/a%25%32%46", http.StatusBadRequest, "", ""},
+		{PathEncoding{}, "/files/a%25%2", http.StatusBadRequest, "", ""},
+		{PathEncoding{AllowDoubleEncoding: true}, "/files/a%252F", http.StatusOK, "/files/:name", "a%252F"},
+		{PathEncoding{}, "/files/a%zz", http.StatusBadRequest, "", ""},
+		{PathEncoding{}, "/files/a%2", http.StatusBadRequest, "", ""},
+		{PathEncoding{AllowDoubleEncoding: true}, "/files/a%", http.StatusBadRequest, "", ""},
+	}
+	for _, tt := range tests {
+		var route, name string
+		handler := func(w http.ResponseWriter, req *http.Request) {
+			name = ParamsFromContext(req.Context()).ByName("name")
+		}
+		router := New()
+		router.Hooks = HooksFunc(func(ev Event) {
+			if ev.Kind == EventMatched {
+				route = ev.Route.Path
+			}
+		})
+		router.UseRawPath = true
+		policy := tt.policy
+		router.PathEncoding = &policy
+		router.GET("/files/:name", handler)
+		router.GET("/a/b", handler)
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.URL.RawPath = tt.rawPath
+		req.URL.Path = tt.rawPath // only compared to RawPath by EscapedPath
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != tt.code || route != tt.route || name != tt.name {
+			t.Errorf("%+v %s: got %d, route %q and name %q, want %d, %q and %q",
+				tt.policy, tt.rawPath, w.Code, route, name, tt.code, tt.route, tt.name)
+		}
+		if req.URL.RawPath != tt.rawPath {
+			t.Errorf("%+v %s: URL of the request was modified to %q", tt.policy, tt.rawPath, req.URL.RawPath)
+		}
+	}
+}
+
+func TestRouterPathEncodingWithoutRawPath(t *testing.T) {
+	var path string
+	router := New()
+	router.PathEncoding = &PathEncoding{EncodedSlashes: DecodeEncodedSlashes}
+	router.GET("/*path", func(w http.ResponseWriter, req *http.Request) {
+		path = ParamsFromContext(req.Context()).ByName("path")
+	})
+
+	// the escapes of URL.Path are checked as well
+	for target, want := range map[string]int{
+		"/a%2Fb":      http.StatusOK,
+		"/a%2541":     http.StatusBadRequest,
+		"/a%25%41":    http.StatusOK,
+		"/a%25%34%31": http.StatusBadRequest,
+		"/a%20b":      http.StatusOK,
+	} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+		if w.Code != want {
+			t.Errorf("%s: got %d, want %d", target, w.Code, want)
+		}
+	}
+
+	path = ""
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/a%2fb", nil))
+	if path != "a/b" {
+		t.Errorf("unexpected path %q", path)
+	}
+}
+
+func TestRouterPathEncodingRejection(t *testing.T) {
+	var events []Event
+	router := New()
+	router.PathEncoding = &PathEncoding{EncodedSlashes: RejectEncodedSlashes}
+	router.Hooks = HooksFunc(func(ev Event) { events = append(events, ev) })
+	router.Metrics = NewMetrics()
+	router.GET("/*path", func(w http.ResponseWriter, req *http.Request) {})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/a%2Fb", nil))
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "encoded slash in path") {
+		t.Errorf("unexpected reply %d %q", w.Code, w.Body.String())
+	}
+	if len(events) != 1 || events[0].Kind != EventRejected || events[0].Err != ErrEncodedSlash || events[0].Status != http.StatusBadRequest {
+		t.Errorf("unexpected events %+v", events)
+	}
+	metrics := httptest.NewRecorder()
+	router.Metrics.ServeHTTP(metrics, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if !strings.Contains(metrics.Body.String(), `outcome="rejected"`) {
+		t.Errorf("rejection not recorded:\n%s", metrics.Body.String())
+	}
+
+	// the error renderer gets the reason as detail
+	var problem *Problem
+	router.ErrorRenderer = func(w http.ResponseWriter, req *http.Request, p *Problem) {
+		problem = p
+		w.WriteHeader(p.Status)
+	}
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/a%2Fb", nil))
+	if problem == nil || problem.Status != http.StatusBadRequest || problem.Detail != "encoded slash in path" {
+		t.Errorf("unexpected problem %+v", problem)
+	}
+}
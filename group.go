@@ -0,0 +1,122 @@
+package httprouter
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Group is a set of routes sharing a common path prefix.
+// Routes registered on the group are registered on its router with the prefix
+// prepended. Settings of the group apply to every request below the prefix,
+// whether a route matches it or not.
+type Group struct {
+	router *Router
+	parent *Group
+	prefix string
+
+	// Metadata attached to every route registered on the group or on any
+	// group within it, unless the route overrides it.
+	Metadata Metadata
+
+	// An optional CORS policy for requests below the prefix of the group.
+	// It overrides the policy of the router and of any enclosing group.
+	CORS *CORS
+}
+
+// Group returns a new group of routes below the given path prefix.
+// The prefix may contain named parameters, but no catch-all.
+func (r *Router) Group(prefix string) *Group {
+	if len(prefix) < 1 || prefix[0] != '/' {
+		panic("prefix must begin with '/' in prefix '" + prefix + "'")
+	}
+	if strings.IndexByte(prefix, '*') >= 0 {
+		panic("prefix must not contain a catch-all in prefix '" + prefix + "'")
+	}
+
+	g := &Group{
+		router: r,
+		prefix: strings.TrimSuffix(prefix, "/"),
+	}
+	if r.groups == nil {
+		r.groups = new(groupIndex)
+	}
+	r.groups.insert(g)
+	return g
+}
+
+// Group returns a new group of routes below the given path prefix, relative to
+// the prefix of g.
+func (g *Group) Group(prefix string) *Group {
+	child := g.router.Group(g.prefix + prefix)
+	child.parent = g
+	return child
+}
+
+// Prefix returns the path prefix of the group.
+func (g *Group) Prefix() string {
+	return g.prefix
+}
+
+// GET is a shortcut for group.HandlerFunc(http.MethodGet, path, handle)
+func (g *Group) GET(path string, handle func(http.ResponseWriter, *http.Request), opts ...RouteOption) {
+	g.HandlerFunc(http.MethodGet, path, handle, opts...)
+}
+
+// HEAD is a shortcut for group.HandlerFunc(http.MethodHead, path, handle)
+func (g *Group) HEAD(path string, handle func(http.ResponseWriter, *http.Request), opts ...RouteOption) {
+	g.HandlerFunc(http.MethodHead, path, handle, opts...)
+}
+
+// OPTIONS is a shortcut for group.HandlerFunc(http.MethodOptions, path, handle)
+func (g *Group) OPTIONS(path string, handle func(http.ResponseWriter, *http.Request), opts ...RouteOption) {
+	g.HandlerFunc(http.MethodOptions, path, handle, opts...)
+}
+
+// POST is a shortcut for group.HandlerFunc(http.MethodPost, path, handle)
+func (g *Group) POST(path string, handle func(http.ResponseWriter, *http.Request), opts ...RouteOption) {
+	g.HandlerFunc(http.MethodPost, path, handle, opts...)
+}
+
+// PUT is a shortcut for group.HandlerFunc(http.MethodPut, path, handle)
+func (g *Group) PUT(path string, handle func(http.ResponseWriter, *http.Request), opts ...RouteOption) {
+	g.HandlerFunc(http.MethodPut, path, handle, opts...)
+}
+
+// PATCH is a shortcut for group.HandlerFunc(http.MethodPatch, path, handle)
+func (g *Group) PATCH(path string, handle func(http.ResponseWriter, *http.Request), opts ...RouteOption) {
+	g.HandlerFunc(http.MethodPatch, path, handle, opts...)
+}
+
+// DELETE is a shortcut for group.HandlerFunc(http.MethodDelete, path, handle)
+func (g *Group) DELETE(path string, handle func(http.ResponseWriter, *http.Request), opts ...RouteOption) {
+	g.HandlerFunc(http.MethodDelete, path, handle, opts...)
+}
+
+// HandlerFunc is an adapter which allows the usage of an http.HandlerFunc as a
+// request handle.
+func (g *Group) HandlerFunc(method, path string, handle func(http.ResponseWriter, *http.Request), opts ...RouteOption) {
+	if handle == nil {
+		panic("handle must not be nil")
+	}
+	g.Handler(method, path, http.HandlerFunc(handle), opts...)
+}
+
+// Handler registers a new request handle with the given path, relative to the
+// prefix of the group, and method.
+// The given options are applied after the metadata of the group.
+func (g *Group) Handler(method, path string, handle http.Handler, opts ...RouteOption) {
+	if len(path) < 1 || path[0] != '/' {
+		panic("path must begin with '/' in path '" + path + "'")
+	}
+	g.router.Handler(method, g.prefix+path, handle, append([]RouteOption{g.applyMetadata}, opts...)...)
+}
+
+// applyMetadata adds the metadata of g and its enclosing groups to a route.
+func (g *Group) applyMetadata(rt *Route) {
+	if g.parent != nil {
+		g.parent.applyMetadata(rt)
+	}
+	for key, value := range g.Metadata {
+		WithMetadata(key, value)(rt)
+	}
+}
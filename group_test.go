@@ -0,0 +1,92 @@
+package httprouter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGroup(t *testing.T) {
+	router := New()
+
+	var served string
+	handler := func(route string) func(http.ResponseWriter, *http.Request) {
+		return func(_ http.ResponseWriter, _ *http.Request) {
+			served = route
+		}
+	}
+
+	api := router.Group("/api/")
+	api.GET("/", handler("/api/"))
+	api.GET("/users/:name", handler("/api/users/:name"))
+
+	v1 := api.Group("/v1")
+	v1.POST("/items", handler("/api/v1/items"))
+
+	if v1.Prefix() != "/api/v1" {
+		t.Errorf("unexpected prefix %q", v1.Prefix())
+	}
+
+	testRoutes := []struct {
+		method string
+		path   string
+		route  string
+	}{
+		{http.MethodGet, "/api/", "/api/"},
+		{http.MethodGet, "/api/users/gopher", "/api/users/:name"},
+		{http.MethodPost, "/api/v1/items", "/api/v1/items"},
+		{http.MethodGet, "/users/gopher", ""},
+	}
+	for _, tr := range testRoutes {
+		served = ""
+		r, _ := http.NewRequest(tr.method, tr.path, nil)
+		router.ServeHTTP(httptest.NewRecorder(), r)
+		if served != tr.route {
+			t.Errorf("%s %s: served %q, want %q", tr.method, tr.path, served, tr.route)
+		}
+	}
+
+	recv := catchPanic(func() {
+		router.Group("api")
+	})
+	if recv == nil {
+		t.Error("creating a group without leading slash did not panic")
+	}
+
+	recv = catchPanic(func() {
+		router.Group("/files/*path")
+	})
+	if recv == nil {
+		t.Error("creating a group with a catch-all did not panic")
+	}
+}
+
+func TestGroupIndexInnermost(t *testing.T) {
+	tests := []struct {
+		prefix   string
+		path     string
+		contains bool
+	}{
+		{"/", "/", true},
+		{"/", "/anything", true},
+		{"/api", "/api", true},
+		{"/api", "/api/", true},
+		{"/api", "/api/users", true},
+		{"/api", "/apis", false},
+		{"/api", "/ap", false},
+		{"/api/v1", "/api/v2/users", false},
+		{"/users/:name", "/users/gopher", true},
+		{"/users/:name", "/users/gopher/repos", true},
+		{"/users/:name", "/users/", false},
+		{"/users/:name/repos", "/users/gopher/repos/x", true},
+		{"/users/:name/repos", "/users/gopher/stars", false},
+	}
+	for _, tt := range tests {
+		router := New()
+		g := router.Group(tt.prefix)
+		g.CORS = &CORS{}
+		if got := router.groups.innermost(tt.path, nil) == g; got != tt.contains {
+			t.Errorf("group %q contains %q: got %t, want %t", tt.prefix, tt.path, got, tt.contains)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package httprouter
+
+import "net/http"
+
+// EventKind identifies a decision made by the router while dispatching a
+// request.
+type EventKind int
+
+// Kinds of events passed to Hooks.
+const (
+	// A route matched the request. Its handler is called right after.
+	EventMatched EventKind = iota
+
+	// The request is redirected to the path with (without) the trailing
+	// slash, see RedirectTrailingSlash.
+	EventRedirectTrailingSlash
+
+	// The request is redirected to the cleaned path, see RedirectFixedPath.
+	EventRedirectFixedPath
+
+	// The request path is corrected and the request is served by the route
+	// of the corrected path, see RewritePaths. It is followed by
+	// EventMatched.
+	EventRewrite
+
+	// The request is answered by the automatic OPTIONS reply, see
+	// HandleOPTIONS.
+	EventOptions
+
+	// The request is answered with 405 (Method Not Allowed), see
+	// HandleMethodNotAllowed.
+	EventMethodNotAllowed
+
+	// No route matched the request.
+	EventNotFound
+
+	// The handler of the request panicked.
+	EventPanic
+
+	// The request was rejected before its handler is called, see
+	// MaxPathLength, MaxSegments, PathEncoding and SafePathValues.
+	EventRejected
+)
+
+var eventKindNames = [...]string{
+	EventMatched:               "matched",
+	EventRedirectTrailingSlash: "redirect_trailing_slash",
+	EventRedirectFixedPath:     "redirect_fixed_path",
+	EventRewrite:               "rewrite",
+	EventOptions:               "options",
+	EventMethodNotAllowed:      "method_not_allowed",
+	EventNotFound:              "not_found",
+	EventPanic:                 "panic",
+	EventRejected:              "rejected",
+}
+
+func (k EventKind) String() string {
+	if k >= 0 && int(k) < len(eventKindNames) {
+		return eventKindNames[k]
+	}
+	return "unknown"
+}
+
+// Event describes a decision made by the router while dispatching a request.
+type Event struct {
+	Kind EventKind
+
+	// The request being dispatched.
+	Request *http.Request
+
+	// The matched route, if any.
+	Route *Route
+
+	// The URL parameters of the matched route, if any.
+	Params Params
+
+	// The status code chosen by the router, or 0 if it is left to a handler,
+	// like for a matched route.
+	Status int
+
+	// The target of a redirect, or the corrected URL of a rewrite.
+	Location string
+
+	// The value the handler panicked with.
+	Panic interface{}
+
+	// The reason the request was rejected.
+	Err error
+}
+
+// Hooks observe the decisions made by a router while dispatching requests.
+// OnEvent is called synchronously before the router acts on the decision, so
+// it must not block and must not write to the response.
+type Hooks interface {
+	OnEvent(Event)
+}
+
+// HooksFunc is an adapter which allows the usage of an ordinary function as
+// Hooks.
+type HooksFunc func(Event)
+
+// OnEvent calls f(ev).
+func (f HooksFunc) OnEvent(ev Event) {
+	f(ev)
+}
+
+// ChainHooks returns Hooks passing every event to each of the given hooks, in
+// order. Nil hooks are skipped.
+func ChainHooks(hooks ...Hooks) Hooks {
+	return chainedHooks(hooks)
+}
+
+type chainedHooks []Hooks
+
+func (c chainedHooks) OnEvent(ev Event) {
+	for _, h := range c {
+		if h != nil {
+			h.OnEvent(ev)
+		}
+	}
+}
+
+// emit passes an event to the hooks of the router, if any.
+func (r *Router) emit(ev Event) {
+	if r.Hooks != nil {
+		r.Hooks.OnEvent(ev)
+	}
+}
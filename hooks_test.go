@@ -0,0 +1,112 @@
+package httprouter
+
+import (
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRouterHooks(t *testing.T) {
+	var events []Event
+	router := New()
+	router.RedirectFixedPath = true
+	router.RecoverPanics = true
+	router.ErrorLog = log.New(ioutil.Discard, "", 0)
+	router.Hooks = HooksFunc(func(ev Event) {
+		events = append(events, ev)
+	})
+	router.GET("/users/:id", func(w http.ResponseWriter, req *http.Request) {
+		if RouteFromContext(req.Context()) == nil {
+			t.Error("matched event passed a request without the route")
+		}
+	})
+	router.GET("/dir/", func(w http.ResponseWriter, req *http.Request) {})
+	router.GET("/panic", func(w http.ResponseWriter, req *http.Request) { panic("boom") })
+
+	tests := []struct {
+		method, path string
+		kinds        []EventKind
+		route        string
+		status       int
+		location     string
+	}{
+		{http.MethodGet, "/users/1", []EventKind{EventMatched}, "/users/:id", 0, ""},
+		{http.MethodGet, "/dir", []EventKind{EventRedirectTrailingSlash}, "", http.StatusMovedPermanently, "/dir/"},
+		{http.MethodGet, "/../dir/", []EventKind{EventRedirectFixedPath}, "", http.StatusMovedPermanently, "/dir/"},
+		{http.MethodOptions, "/users/1", []EventKind{EventOptions}, "", http.StatusOK, ""},
+		{http.MethodDelete, "/users/1", []EventKind{EventMethodNotAllowed}, "", http.StatusMethodNotAllowed, ""},
+		{http.MethodGet, "/missing", []EventKind{EventNotFound}, "", http.StatusNotFound, ""},
+		{http.MethodGet, "/panic", []EventKind{EventMatched, EventPanic}, "/panic", http.StatusInternalServerError, ""},
+	}
+	for _, tt := range tests {
+		events = nil
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		if tt.path == "/../dir/" {
+			req.URL.Path = tt.path
+		}
+		router.ServeHTTP(httptest.NewRecorder(), req)
+
+		if len(events) != len(tt.kinds) {
+			t.Errorf("%s %s: got %d events, want %d", tt.method, tt.path, len(events), len(tt.kinds))
+			continue
+		}
+		for i, kind := range tt.kinds {
+			if events[i].Kind != kind {
+				t.Errorf("%s %s: event %d is %v, want %v", tt.method, tt.path, i, events[i].Kind, kind)
+			}
+		}
+		last := events[len(events)-1]
+		route := ""
+		if last.Route != nil {
+			route = last.Route.Path
+		}
+		if route != tt.route || last.Status != tt.status || last.Location != tt.location {
+			t.Errorf("%s %s: unexpected event %v with route %q, status %d and location %q",
+				tt.method, tt.path, last.Kind, route, last.Status, last.Location)
+		}
+		if last.Request == nil {
+			t.Errorf("%s %s: event without request", tt.method, tt.path)
+		}
+	}
+
+	if events[1].Panic != "boom" || events[1].Params != nil {
+		t.Errorf("unexpected panic event %+v", events[1])
+	}
+}
+
+func TestRouterHooksUnrecoveredPanic(t *testing.T) {
+	var kinds []EventKind
+	router := New()
+	router.Hooks = HooksFunc(func(ev Event) {
+		kinds = append(kinds, ev.Kind)
+	})
+	router.GET("/panic", func(w http.ResponseWriter, req *http.Request) { panic("boom") })
+
+	recv := catchPanic(func() {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/panic", nil))
+	})
+	if recv != "boom" {
+		t.Fatalf("panic was not passed on: %v", recv)
+	}
+	if len(kinds) != 2 || kinds[1] != EventPanic {
+		t.Errorf("unexpected events %v", kinds)
+	}
+}
+
+func TestChainHooks(t *testing.T) {
+	var calls []string
+	hooks := ChainHooks(
+		HooksFunc(func(ev Event) { calls = append(calls, "first "+ev.Kind.String()) }),
+		nil,
+		HooksFunc(func(ev Event) { calls = append(calls, "second "+ev.Kind.String()) }),
+	)
+	hooks.OnEvent(Event{Kind: EventNotFound})
+	if len(calls) != 2 || calls[0] != "first not_found" || calls[1] != "second not_found" {
+		t.Errorf("unexpected calls %v", calls)
+	}
+	if s := EventKind(-1).String(); s != "unknown" {
+		t.Errorf("unexpected name %q for invalid kind", s)
+	}
+}
@@ -0,0 +1,26 @@
+package httprouter
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// Errors of requests rejected because of the limits of a router, see
+// Router.MaxPathLength and Router.MaxSegments.
+var (
+	ErrPathTooLong     = errors.New("httprouter: path too long")
+	ErrTooManySegments = errors.New("httprouter: too many path segments")
+)
+
+// checkLimits checks a request path against the limits of the router. It
+// returns the status code to reject the request with, if any.
+func (r *Router) checkLimits(path string) (int, error) {
+	if r.MaxPathLength > 0 && len(path) > r.MaxPathLength {
+		return http.StatusRequestURITooLong, ErrPathTooLong
+	}
+	if r.MaxSegments > 0 && strings.Count(path, "/") > r.MaxSegments {
+		return http.StatusBadRequest, ErrTooManySegments
+	}
+	return 0, nil
+}
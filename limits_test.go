@@ -0,0 +1,67 @@
+package httprouter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRouterLimits(t *testing.T) {
+	router := New()
+	router.MaxPathLength = 32
+	router.MaxSegments = 4
+	router.GET("/*path", func(w http.ResponseWriter, req *http.Request) {})
+
+	tests := []struct {
+		path string
+		code int
+	}{
+		{"/a/b/c/d", http.StatusOK},
+		{"/a/b/c/d/", http.StatusBadRequest},
+		{"/a/b/c/d/e", http.StatusBadRequest},
+		{"/" + strings.Repeat("a", 31), http.StatusOK},
+		{"/" + strings.Repeat("a", 32), http.StatusRequestURITooLong},
+		{"/" + strings.Repeat("a/", 20), http.StatusRequestURITooLong},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if w.Code != tt.code {
+			t.Errorf("%s: got %d, want %d", tt.path, w.Code, tt.code)
+		}
+	}
+
+	// with UseRawPath the escaped path counts
+	router.UseRawPath = true
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/"+strings.Repeat("%2F", 11), nil))
+	if w.Code != http.StatusRequestURITooLong {
+		t.Errorf("got %d for long escaped path", w.Code)
+	}
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/"+strings.Repeat("%2F", 10), nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("got %d for escaped slashes", w.Code)
+	}
+}
+
+func TestRouterLimitsRejection(t *testing.T) {
+	var events []Event
+	router := New()
+	router.MaxSegments = 1
+	router.Hooks = HooksFunc(func(ev Event) { events = append(events, ev) })
+
+	var problem *Problem
+	router.ErrorRenderer = func(w http.ResponseWriter, req *http.Request, p *Problem) {
+		problem = p
+		w.WriteHeader(p.Status)
+	}
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/a/b", nil))
+	if problem == nil || problem.Status != http.StatusBadRequest || problem.Detail != "too many path segments" {
+		t.Errorf("unexpected problem %+v", problem)
+	}
+	if len(events) != 1 || events[0].Kind != EventRejected || events[0].Err != ErrTooManySegments {
+		t.Errorf("unexpected events %+v", events)
+	}
+}
@@ -0,0 +1,203 @@
+package httprouter
+
+import (
+	"bytes"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Outcomes of the dispatch of a request, as recorded by Metrics.
+const (
+	OutcomeMatched          = "matched"
+	OutcomeRedirect         = "redirect"
+	OutcomeOptions          = "options"
+	OutcomeMethodNotAllowed = "method_not_allowed"
+	OutcomeNotFound         = "not_found"
+	OutcomePanic            = "panic"
+	OutcomeRejected         = "rejected"
+)
+
+// DefaultBuckets are the default upper bounds of the latency histogram
+// buckets of Metrics, in seconds.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Metrics records request counts and latencies of a router, labeled by the
+// request method and the matched route path rather than the request path.
+// Requests not matching any route, like 404, 405, redirects and automatic
+// OPTIONS replies, are recorded with an empty route.
+//
+// Metrics implements http.Handler, serving the recorded values in the
+// Prometheus text exposition format:
+//
+//	router.Metrics = httprouter.NewMetrics()
+//	router.Handler(http.MethodGet, "/metrics", router.Metrics)
+type Metrics struct {
+	buckets []float64
+
+	mu        sync.Mutex
+	requests  map[requestLabels]uint64
+	latencies map[latencyLabels]*histogram
+}
+
+type requestLabels struct {
+	method, route, outcome, class string
+}
+
+type latencyLabels struct {
+	method, route string
+}
+
+type histogram struct {
+	counts []uint64 // per bucket, not cumulative
+	sum    float64
+	count  uint64
+}
+
+// NewMetrics returns new metrics with the given latency histogram buckets in
+// seconds, or DefaultBuckets if none are given.
+func NewMetrics(buckets ...float64) *Metrics {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+	return &Metrics{
+		buckets:   buckets,
+		requests:  make(map[requestLabels]uint64),
+		latencies: make(map[latencyLabels]*histogram),
+	}
+}
+
+// observe records a served request.
+func (m *Metrics) observe(method, route, outcome string, status int, d time.Duration) {
+	method = metricsMethod(method)
+	class := strconv.Itoa(status/100) + "xx"
+	seconds := d.Seconds()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.requests[requestLabels{method, route, outcome, class}]++
+
+	key := latencyLabels{method, route}
+	h := m.latencies[key]
+	if h == nil {
+		h = &histogram{counts: make([]uint64, len(m.buckets))}
+		m.latencies[key] = h
+	}
+	if i := sort.SearchFloat64s(m.buckets, seconds); i < len(m.buckets) {
+		h.counts[i]++
+	}
+	h.sum += seconds
+	h.count++
+}
+
+// metricsMethod maps non-standard methods to a single label value, so clients
+// can't create arbitrarily many time series.
+func metricsMethod(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut,
+		http.MethodPatch, http.MethodDelete, http.MethodConnect,
+		http.MethodOptions, http.MethodTrace:
+		return method
+	}
+	return "OTHER"
+}
+
+// ServeHTTP writes the recorded metrics in the Prometheus text exposition
+// format.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	// rendered up front, a slow client mustn't hold up the observed requests
+	var buf bytes.Buffer
+	m.write(&buf)
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	buf.WriteTo(w)
+}
+
+func (m *Metrics) write(w *bytes.Buffer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	requests := make([]requestLabels, 0, len(m.requests))
+	for key := range m.requests {
+		requests = append(requests, key)
+	}
+	sort.Slice(requests, func(i, j int) bool {
+		a, b := requests[i], requests[j]
+		if a.route != b.route {
+			return a.route < b.route
+		}
+		if a.method != b.method {
+			return a.method < b.method
+		}
+		if a.outcome != b.outcome {
+			return a.outcome < b.outcome
+		}
+		return a.class < b.class
+	})
+
+	w.WriteString("# HELP httprouter_requests_total Total number of requests by method, route, outcome and status class.\n")
+	w.WriteString("# TYPE httprouter_requests_total counter\n")
+	for _, key := range requests {
+		w.WriteString("httprouter_requests_total")
+		writeLabels(w, "method", key.method, "route", key.route, "outcome", key.outcome, "class", key.class)
+		w.WriteString(" " + strconv.FormatUint(m.requests[key], 10) + "\n")
+	}
+
+	latencies := make([]latencyLabels, 0, len(m.latencies))
+	for key := range m.latencies {
+		latencies = append(latencies, key)
+	}
+	sort.Slice(latencies, func(i, j int) bool {
+		a, b := latencies[i], latencies[j]
+		if a.route != b.route {
+			return a.route < b.route
+		}
+		return a.method < b.method
+	})
+
+	w.WriteString("# HELP httprouter_request_duration_seconds Latency of requests by method and route.\n")
+	w.WriteString("# TYPE httprouter_request_duration_seconds histogram\n")
+	for _, key := range latencies {
+		h := m.latencies[key]
+		var cumulative uint64
+		for i, le := range m.buckets {
+			cumulative += h.counts[i]
+			w.WriteString("httprouter_request_duration_seconds_bucket")
+			writeLabels(w, "method", key.method, "route", key.route, "le", formatFloat(le))
+			w.WriteString(" " + strconv.FormatUint(cumulative, 10) + "\n")
+		}
+		w.WriteString("httprouter_request_duration_seconds_bucket")
+		writeLabels(w, "method", key.method, "route", key.route, "le", "+Inf")
+		w.WriteString(" " + strconv.FormatUint(h.count, 10) + "\n")
+
+		w.WriteString("httprouter_request_duration_seconds_sum")
+		writeLabels(w, "method", key.method, "route", key.route)
+		w.WriteString(" " + formatFloat(h.sum) + "\n")
+		w.WriteString("httprouter_request_duration_seconds_count")
+		writeLabels(w, "method", key.method, "route", key.route)
+		w.WriteString(" " + strconv.FormatUint(h.count, 10) + "\n")
+	}
+}
+
+// writeLabels writes a label set from pairs of names and values.
+func writeLabels(w *bytes.Buffer, pairs ...string) {
+	w.WriteByte('{')
+	for i := 0; i < len(pairs); i += 2 {
+		if i > 0 {
+			w.WriteByte(',')
+		}
+		w.WriteString(pairs[i] + `="` + labelEscaper.Replace(pairs[i+1]) + `"`)
+	}
+	w.WriteByte('}')
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
@@ -0,0 +1,154 @@
+package httprouter
+
+import (
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMetrics(t *testing.T) {
+	router := New()
+	router.Metrics = NewMetrics(0.1, 1)
+	router.RecoverPanics = true
+	router.ErrorLog = log.New(ioutil.Discard, "", 0)
+	router.GET("/users/:id", func(w http.ResponseWriter, req *http.Request) {})
+	router.GET("/dir/", func(w http.ResponseWriter, req *http.Request) {})
+	router.GET("/panic", func(w http.ResponseWriter, req *http.Request) { panic("boom") })
+	router.Handler(http.MethodGet, "/metrics", router.Metrics)
+
+	for _, req := range []struct {
+		method, path string
+	}{
+		{http.MethodGet, "/users/1"},
+		{http.MethodGet, "/users/2"},
+		{http.MethodGet, "/missing"},
+		{http.MethodPost, "/users/1"},
+		{http.MethodOptions, "/users/1"},
+		{http.MethodGet, "/dir"},
+		{http.MethodGet, "/panic"},
+		{"PURGE", "/users/1"},
+	} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(req.method, req.path, nil))
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("unexpected content type %q", ct)
+	}
+
+	body := w.Body.String()
+	for _, line := range []string{
+		`# TYPE httprouter_requests_total counter`,
+		`httprouter_requests_total{method="GET",route="/users/:id",outcome="matched",class="2xx"} 2`,
+		`httprouter_requests_total{method="GET",route="",outcome="not_found",class="4xx"} 1`,
+		`httprouter_requests_total{method="POST",route="",outcome="method_not_allowed",class="4xx"} 1`,
+		`httprouter_requests_total{method="OPTIONS",route="",outcome="options",class="2xx"} 1`,
+		`httprouter_requests_total{method="GET",route="",outcome="redirect",class="3xx"} 1`,
+		`httprouter_requests_total{method="GET",route="/panic",outcome="panic",class="5xx"} 1`,
+		`httprouter_requests_total{method="OTHER",route="",outcome="method_not_allowed",class="4xx"} 1`,
+		`# TYPE httprouter_request_duration_seconds histogram`,
+		`httprouter_request_duration_seconds_bucket{method="GET",route="/users/:id",le="1"} 2`,
+		`httprouter_request_duration_seconds_bucket{method="GET",route="/users/:id",le="+Inf"} 2`,
+		`httprouter_request_duration_seconds_count{method="GET",route="/users/:id"} 2`,
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("missing line %q in:\n%s", line, body)
+		}
+	}
+}
+
+func TestMetricsUnrecoveredPanic(t *testing.T) {
+	router := New()
+	router.Metrics = NewMetrics()
+	router.GET("/panic", func(w http.ResponseWriter, req *http.Request) { panic("boom") })
+
+	recv := catchPanic(func() {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/panic", nil))
+	})
+	if recv != "boom" {
+		t.Fatalf("panic was not passed on: %v", recv)
+	}
+
+	w := httptest.NewRecorder()
+	router.Metrics.ServeHTTP(w, nil)
+	line := `httprouter_requests_total{method="GET",route="/panic",outcome="panic",class="5xx"} 1`
+	if !strings.Contains(w.Body.String(), line) {
+		t.Errorf("missing line %q in:\n%s", line, w.Body.String())
+	}
+}
+
+func TestMetricsHistogram(t *testing.T) {
+	m := NewMetrics(1, 0.1)
+	m.observe(http.MethodGet, "/", OutcomeMatched, 200, 50*time.Millisecond)
+	m.observe(http.MethodGet, "/", OutcomeMatched, 200, 500*time.Millisecond)
+	m.observe(http.MethodGet, "/", OutcomeMatched, 200, 5*time.Second)
+
+	w := httptest.NewRecorder()
+	m.ServeHTTP(w, nil)
+	for _, line := range []string{
+		`httprouter_request_duration_seconds_bucket{method="GET",route="/",le="0.1"} 1`,
+		`httprouter_request_duration_seconds_bucket{method="GET",route="/",le="1"} 2`,
+		`httprouter_request_duration_seconds_bucket{method="GET",route="/",le="+Inf"} 3`,
+		`httprouter_request_duration_seconds_sum{method="GET",route="/"} 5.55`,
+	} {
+		if !strings.Contains(w.Body.String(), line+"\n") {
+			t.Errorf("missing line %q in:\n%s", line, w.Body.String())
+		}
+	}
+}
+
+// blockingWriter blocks writing the body until released.
+type blockingWriter struct {
+	*httptest.ResponseRecorder
+	once    sync.Once
+	writing chan struct{}
+	release chan struct{}
+}
+
+func (w *blockingWriter) Write(b []byte) (int, error) {
+	w.once.Do(func() { close(w.writing) })
+	<-w.release
+	return w.ResponseRecorder.Write(b)
+}
+
+func TestMetricsSlowScrape(t *testing.T) {
+	m := NewMetrics()
+	// more than fits a write buffer
+	for i := 0; i < 100; i++ {
+		m.observe(http.MethodGet, "/"+strconv.Itoa(i), OutcomeMatched, http.StatusOK, time.Millisecond)
+	}
+
+	w := &blockingWriter{ResponseRecorder: httptest.NewRecorder(), writing: make(chan struct{}), release: make(chan struct{})}
+	done := make(chan struct{})
+	go func() {
+		m.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		close(done)
+	}()
+	<-w.writing
+
+	// requests are still observed while the scrape is being written
+	observed := make(chan struct{})
+	go func() {
+		m.observe(http.MethodGet, "/", OutcomeMatched, http.StatusOK, time.Millisecond)
+		close(observed)
+	}()
+	select {
+	case <-observed:
+	case <-time.After(time.Second):
+		t.Error("observe blocked by a slow scrape")
+	}
+
+	close(w.release)
+	<-done
+	<-observed
+	if !strings.Contains(w.Body.String(), `httprouter_requests_total{method="GET",route="/99",outcome="matched",class="2xx"} 1`) {
+		t.Errorf("unexpected body %q", w.Body.String())
+	}
+}
@@ -0,0 +1,198 @@
+package httprouter
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+type mountPrefixKey struct{}
+
+// mountRawPrefixKey is the context key of the escaped form of the mount
+// prefix.
+type mountRawPrefixKey struct{}
+
+// MountPrefixKey is the request context key under which the path prefix a
+// handler is mounted at is stored, see Router.Mount.
+var MountPrefixKey = mountPrefixKey{}
+
+// MountPrefixFromContext pulls the path prefix the handler of a request is
+// mounted at from a request context, like "/tenants/acme/admin" for a handler
+// mounted at /tenants/:tenant/admin. It returns "" if the handler isn't
+// mounted.
+func MountPrefixFromContext(ctx context.Context) string {
+	prefix, _ := ctx.Value(MountPrefixKey).(string)
+	return prefix
+}
+
+// mountRawPrefix returns the escaped form of the mount prefix stored in ctx.
+func mountRawPrefix(ctx context.Context) string {
+	prefix, _ := ctx.Value(mountRawPrefixKey{}).(string)
+	return prefix
+}
+
+// Mount delegates all requests below the given path prefix to handler,
+// whatever their method. The prefix may contain named parameters, but no
+// catch-all.
+//
+// The prefix is stripped from URL.Path and URL.RawPath of the requests passed
+// to handler, so that e.g. a request for /admin/users reaches a handler
+// mounted at /admin with the path /users. The stripped prefix is stored in
+// the request context, see MountPrefixFromContext, and the values of its
+// named parameters are available as Params.
+//
+// Routes registered on the router take priority over mounted handlers.
+//
+// If handler is a Router, it is mounted as a sub-router: requests it has no
+// route for are answered by r, which then includes the methods of the
+// sub-router in its automatic OPTIONS and 405 replies. The Params of the
+// sub-router are appended to those of the prefix.
+func (r *Router) Mount(prefix string, handler http.Handler) {
+	if len(prefix) < 1 || prefix[0] != '/' {
+		panic("prefix must begin with '/' in prefix '" + prefix + "'")
+	}
+	if strings.IndexByte(prefix, '*') >= 0 {
+		panic("prefix must not contain a catch-all in prefix '" + prefix + "'")
+	}
+	if handler == nil {
+		panic("handler must not be nil")
+	}
+
+	prefix = strings.TrimSuffix(prefix, "/")
+	m := &mount{router: r, handler: handler}
+	m.child, _ = handler.(*Router)
+	if r.mounts == nil {
+		r.mounts = new(node)
+	}
+	if prefix != "" {
+		r.mounts.insertRoute(&Route{Path: prefix, Handler: m})
+	}
+	// a catch-all doesn't match an empty remainder
+	r.mounts.insertRoute(&Route{Path: prefix + "/", Handler: m})
+	r.mounts.insertRoute(&Route{Path: prefix + "/*", Handler: m})
+}
+
+// lookupMount finds the node of the handler mounted at a prefix of the given
+// path, if any.
+func (r *Router) lookupMount(path string) (*node, Params) {
+	if r.mounts == nil {
+		return nil, nil
+	}
+	n, values, _ := r.mounts.search(path)
+	if n == nil || n.handle == nil {
+		return nil, nil
+	}
+
+	var params Params
+	for i, name := range n.wildcardNames {
+		if name == "*" {
+			name = catchAllParam
+		}
+		params = append(params, Param{Key: name, Value: values[i]})
+	}
+	return n, params
+}
+
+// mount is the handle of the routes of a mounted handler.
+type mount struct {
+	router  *Router
+	handler http.Handler
+	child   *Router // if handler is a sub-router
+}
+
+// subPath returns the path below the prefix of a mount for a path and the
+// params it matched the mount with.
+func subPath(params Params) string {
+	if len(params) > 0 && params[len(params)-1].Key == catchAllParam {
+		return "/" + params[len(params)-1].Value
+	}
+	return "/"
+}
+
+// serves reports whether a request for the given method and path below the
+// prefix of m is served by the mounted handler. Sub-routers only serve the
+// requests they have a route or a redirect for, the others are left to the
+// parent router.
+func (m *mount) serves(method, path string) bool {
+	r := m.child
+	if r == nil {
+		return true
+	}
+	n, _, tsr := r.lookup(method, path)
+	if n != nil || tsr && r.RedirectTrailingSlash && path != "/" && method != http.MethodConnect {
+		return true
+	}
+	if n, params := r.lookupMount(path); n != nil {
+		return n.handle.(*mount).serves(method, subPath(params))
+	}
+	return false
+}
+
+func (m *mount) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	raw := m.router.UseRawPath && len(req.URL.RawPath) > 0
+	path := m.router.matchPath(req.URL)
+
+	// the remaining path is the value of the catch-all, if the request
+	// didn't match the prefix exactly
+	params := ParamsFromContext(req.Context())
+	n := len(path)
+	if len(params) > 0 && params[len(params)-1].Key == catchAllParam {
+		// the raw value was matched against the path
+		n -= len(params[len(params)-1].raw()) + 1
+		params = params[:len(params)-1]
+	} else if strings.HasSuffix(path, "/") {
+		n--
+	}
+
+	u := *req.URL
+	prefix, rawPrefix := stripPrefix(&u, n, raw)
+
+	ctx := req.Context()
+	ctx = context.WithValue(ctx, MountPrefixKey, MountPrefixFromContext(ctx)+prefix)
+	ctx = context.WithValue(ctx, mountRawPrefixKey{}, mountRawPrefix(ctx)+rawPrefix)
+	if len(params) > 0 {
+		ctx = context.WithValue(ctx, ParamsKey, params)
+	} else if ctx.Value(ParamsKey) != nil {
+		ctx = context.WithValue(ctx, ParamsKey, Params(nil))
+	}
+	req = req.WithContext(ctx)
+	req.URL = &u
+	m.handler.ServeHTTP(w, req)
+}
+
+// stripPrefix removes the first n bytes of the path from u and returns them
+// unescaped and escaped. If raw is set, n counts the bytes of the escaped path, otherwise
+// of the unescaped one. URL.Path and URL.RawPath are kept consistent, even if
+// the prefix contains escaped characters.
+func stripPrefix(u *url.URL, n int, raw bool) (prefix, rawPrefix string) {
+	escaped := u.EscapedPath()
+
+	// i indexes the escaped path, j the unescaped one
+	i, j := 0, 0
+	for i < len(escaped) && j < len(u.Path) {
+		if raw && i >= n || !raw && j >= n {
+			break
+		}
+		if escaped[i] == '%' && i+2 < len(escaped) {
+			i += 3
+		} else {
+			i++
+		}
+		j++
+	}
+
+	prefix, rawPrefix = u.Path[:j], escaped[:i]
+	u.Path = u.Path[j:]
+	if u.RawPath != "" {
+		u.RawPath = escaped[i:]
+	}
+	if u.Path == "" {
+		u.Path = "/"
+		u.RawPath = ""
+	} else if u.RawPath != "" && (&url.URL{Path: u.Path}).EscapedPath() == u.RawPath {
+		// the default encoding of the path is the same
+		u.RawPath = ""
+	}
+	return prefix, rawPrefix
+}
@@ -0,0 +1,205 @@
+package httprouter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+type mountRecorder struct {
+	path, rawPath, prefix string
+	params                Params
+	calls                 int
+}
+
+func (m *mountRecorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	m.calls++
+	m.path = req.URL.Path
+	m.rawPath = req.URL.RawPath
+	m.prefix = MountPrefixFromContext(req.Context())
+	m.params = ParamsFromContext(req.Context())
+}
+
+func TestRouterMount(t *testing.T) {
+	admin := new(mountRecorder)
+	tenant := new(mountRecorder)
+
+	router := New()
+	router.GET("/admin/login", func(w http.ResponseWriter, req *http.Request) {})
+	router.Mount("/admin/", admin)
+	router.Mount("/tenants/:tenant/app", tenant)
+
+	tests := []struct {
+		method, target string
+		rec            *mountRecorder
+		path, rawPath  string
+		prefix         string
+		params         Params
+	}{
+		{http.MethodGet, "/admin", admin, "/", "", "/admin", nil},
+		{http.MethodGet, "/admin/", admin, "/", "", "/admin", nil},
+		{http.MethodPost, "/admin/debug/pprof/", admin, "/debug/pprof/", "", "/admin", nil},
+		{http.MethodDelete, "/admin/a%2Fb/c", admin, "/a/b/c", "/a%2Fb/c", "/admin", nil},
+		{http.MethodGet, "/tenants/acme/app/x%20y", tenant, "/x y", "", "/tenants/acme/app", Params{{Key: "tenant", Value: "acme"}}},
+		{http.MethodPut, "/tenants/acme/app/", tenant, "/", "", "/tenants/acme/app", Params{{Key: "tenant", Value: "acme"}}},
+	}
+	for _, tt := range tests {
+		*tt.rec = mountRecorder{}
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(tt.method, tt.target, nil))
+		if tt.rec.calls != 1 {
+			t.Errorf("%s %s: mounted handler was called %d times", tt.method, tt.target, tt.rec.calls)
+			continue
+		}
+		if tt.rec.path != tt.path || tt.rec.rawPath != tt.rawPath || tt.rec.prefix != tt.prefix {
+			t.Errorf("%s %s: got path %q, raw path %q and prefix %q, want %q, %q and %q", tt.method, tt.target,
+				tt.rec.path, tt.rec.rawPath, tt.rec.prefix, tt.path, tt.rawPath, tt.prefix)
+		}
+		if !reflect.DeepEqual(tt.rec.params, tt.params) {
+			t.Errorf("%s %s: got params %v, want %v", tt.method, tt.target, tt.rec.params, tt.params)
+		}
+	}
+
+	// routes take priority
+	admin.calls = 0
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/login", nil))
+	if admin.calls != 0 {
+		t.Error("mounted handler took priority over a route")
+	}
+
+	// prefixes only match whole segments
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/administrator", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("unexpected status %d for a path sharing a partial segment", w.Code)
+	}
+
+	route, _, _ := router.Lookup(http.MethodPatch, "/admin/x")
+	if route == nil || route.Path != "/admin/*" {
+		t.Errorf("unexpected route %v for mounted handler", route)
+	}
+}
+
+func TestRouterMountRawPath(t *testing.T) {
+	rec := new(mountRecorder)
+	router := New()
+	router.UseRawPath = true
+	router.Mount("/files/:dir", rec)
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/files/a%2Fb/c%2Fd", nil))
+	if rec.path != "/c/d" || rec.rawPath != "/c%2Fd" || rec.prefix != "/files/a/b" {
+		t.Errorf("got path %q, raw path %q and prefix %q", rec.path, rec.rawPath, rec.prefix)
+	}
+	if want := (Params{{Key: "dir", Value: "a%2Fb"}}); !reflect.DeepEqual(rec.params, want) {
+		t.Errorf("got params %v, want %v", rec.params, want)
+	}
+}
+
+func TestRouterMountNested(t *testing.T) {
+	rec := new(mountRecorder)
+	inner := New()
+	inner.Mount("/v1", rec)
+	router := New()
+	router.Mount("/api", inner)
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/v1/users", nil))
+	if rec.path != "/users" || rec.prefix != "/api/v1" {
+		t.Errorf("got path %q and prefix %q", rec.path, rec.prefix)
+	}
+}
+
+func TestRouterMountRoot(t *testing.T) {
+	rec := new(mountRecorder)
+	router := New()
+	router.GET("/api", func(w http.ResponseWriter, req *http.Request) {})
+	router.Mount("/", rec)
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/index.html", nil))
+	if rec.path != "/index.html" || rec.prefix != "" {
+		t.Errorf("got path %q and prefix %q", rec.path, rec.prefix)
+	}
+}
+
+func TestRouterMountInvalid(t *testing.T) {
+	router := New()
+	for _, prefix := range []string{"", "admin", "/files/*path"} {
+		if recv := catchPanic(func() { router.Mount(prefix, http.NotFoundHandler()) }); recv == nil {
+			t.Errorf("no panic for prefix %q", prefix)
+		}
+	}
+	router.Mount("/admin", http.NotFoundHandler())
+	if recv := catchPanic(func() { router.Mount("/admin/", http.NotFoundHandler()) }); recv == nil {
+		t.Error("no panic for mounting the same prefix twice")
+	}
+}
+
+func TestStripPrefix(t *testing.T) {
+	u, _ := url.Parse("/a%2Fb/c%20d/e")
+	prefix, rawPrefix := stripPrefix(u, len("/a/b"), false)
+	if prefix != "/a/b" || rawPrefix != "/a%2Fb" || u.Path != "/c d/e" || u.RawPath != "" {
+		t.Errorf("got prefix %q, path %q and raw path %q", prefix, u.Path, u.RawPath)
+	}
+}
+
+func TestRouterMountSubRouter(t *testing.T) {
+	var params Params
+	child := New()
+	child.GET("/users/:id", func(w http.ResponseWriter, req *http.Request) {
+		params = ParamsFromContext(req.Context())
+	})
+	child.POST("/users/:id", func(w http.ResponseWriter, req *http.Request) {})
+	child.GET("/dir/", func(w http.ResponseWriter, req *http.Request) {})
+
+	parent := New()
+	parent.DELETE("/tenants/:tenant/users/:id", func(w http.ResponseWriter, req *http.Request) {})
+	parent.Mount("/tenants/:tenant", child)
+
+	serve := func(method, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		parent.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+		return w
+	}
+
+	// the params of the sub-router are appended to those of the prefix
+	if w := serve(http.MethodGet, "/tenants/acme/users/42"); w.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d", w.Code)
+	}
+	if want := (Params{{Key: "tenant", Value: "acme"}, {Key: "id", Value: "42"}}); !reflect.DeepEqual(params, want) {
+		t.Errorf("got params %v, want %v", params, want)
+	}
+
+	// the routes of the parent still match
+	if w := serve(http.MethodDelete, "/tenants/acme/users/42"); w.Code != http.StatusOK {
+		t.Errorf("unexpected status %d for route of the parent", w.Code)
+	}
+
+	// 405 and OPTIONS replies list the methods of both routers
+	w := serve(http.MethodPut, "/tenants/acme/users/42")
+	if w.Code != http.StatusMethodNotAllowed || w.Header().Get("Allow") != "DELETE, GET, OPTIONS, POST" {
+		t.Errorf("unexpected status %d and Allow %q", w.Code, w.Header().Get("Allow"))
+	}
+	w = serve(http.MethodOptions, "/tenants/acme/users/42")
+	if w.Code != http.StatusOK || w.Header().Get("Allow") != "DELETE, GET, OPTIONS, POST" {
+		t.Errorf("unexpected status %d and Allow %q for OPTIONS", w.Code, w.Header().Get("Allow"))
+	}
+	w = serve(http.MethodOptions, "*")
+	if allow := w.Header().Get("Allow"); allow != "DELETE, GET, OPTIONS, POST" {
+		t.Errorf("unexpected server-wide Allow %q", allow)
+	}
+
+	// redirects of the sub-router keep the prefix
+	w = serve(http.MethodGet, "/tenants/acme/dir")
+	if w.Code != http.StatusMovedPermanently || w.Header().Get("Location") != "/tenants/acme/dir/" {
+		t.Errorf("unexpected status %d and Location %q", w.Code, w.Header().Get("Location"))
+	}
+	w = serve(http.MethodGet, "/tenants/a%20b/dir?q=1")
+	if w.Code != http.StatusMovedPermanently || w.Header().Get("Location") != "/tenants/a%20b/dir/?q=1" {
+		t.Errorf("unexpected status %d and Location %q for escaped prefix", w.Code, w.Header().Get("Location"))
+	}
+
+	if w := serve(http.MethodGet, "/tenants/acme/missing"); w.Code != http.StatusNotFound {
+		t.Errorf("unexpected status %d for missing route", w.Code)
+	}
+}
@@ -0,0 +1,539 @@
+package httprouter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// Metadata keys read by the OpenAPI document generator.
+// Attach them to routes with WithMetadata.
+const (
+	// The operationId of the route, a string.
+	MetaOperationID = "openapi.operationId"
+
+	// A short summary of the route, a string.
+	MetaSummary = "openapi.summary"
+
+	// A verbose description of the route, a string.
+	MetaDescription = "openapi.description"
+
+	// The tags of the route, a []string, or a []interface{} of strings as
+	// decoded from a Config.
+	MetaTags = "openapi.tags"
+
+	// Whether the route is deprecated, a bool.
+	MetaDeprecated = "openapi.deprecated"
+
+	// The JSON schema of the request body. Any value marshaling to a JSON
+	// schema object, e.g. a map or a json.RawMessage.
+	MetaRequestSchema = "openapi.requestSchema"
+
+	// The JSON schema of the 200 response body, like MetaRequestSchema.
+	MetaResponseSchema = "openapi.responseSchema"
+)
+
+// metaStrings returns a list of strings attached to a route, either set as a
+// []string or decoded from a Config as a []interface{}.
+func metaStrings(v interface{}) []string {
+	switch v := v.(type) {
+	case []string:
+		return v
+	case []interface{}:
+		strs := make([]string, 0, len(v))
+		for _, item := range v {
+			if str, ok := item.(string); ok {
+				strs = append(strs, str)
+			}
+		}
+		return strs
+	}
+	return nil
+}
+
+// OpenAPIDocument is an OpenAPI 3 document, restricted to the parts describing
+// routes.
+type OpenAPIDocument struct {
+	OpenAPI string                      `json:"openapi"`
+	Info    OpenAPIInfo                 `json:"info"`
+	Servers []OpenAPIServer             `json:"servers,omitempty"`
+	Paths   map[string]*OpenAPIPathItem `json:"paths"`
+}
+
+// OpenAPIInfo is the metadata of an API.
+type OpenAPIInfo struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+// OpenAPIServer is a server hosting an API.
+type OpenAPIServer struct {
+	URL         string `json:"url"`
+	Description string `json:"description,omitempty"`
+}
+
+// OpenAPIPathItem describes the operations available on a single path.
+type OpenAPIPathItem struct {
+	Summary     string              `json:"summary,omitempty"`
+	Description string              `json:"description,omitempty"`
+	Get         *OpenAPIOperation   `json:"get,omitempty"`
+	Put         *OpenAPIOperation   `json:"put,omitempty"`
+	Post        *OpenAPIOperation   `json:"post,omitempty"`
+	Delete      *OpenAPIOperation   `json:"delete,omitempty"`
+	Options     *OpenAPIOperation   `json:"options,omitempty"`
+	Head        *OpenAPIOperation   `json:"head,omitempty"`
+	Patch       *OpenAPIOperation   `json:"patch,omitempty"`
+	Trace       *OpenAPIOperation   `json:"trace,omitempty"`
+	Parameters  []*OpenAPIParameter `json:"parameters,omitempty"`
+}
+
+// Operations returns the operations of the path item by request method.
+func (item *OpenAPIPathItem) Operations() map[string]*OpenAPIOperation {
+	ops := make(map[string]*OpenAPIOperation, 8)
+	for method, op := range map[string]*OpenAPIOperation{
+		http.MethodGet:     item.Get,
+		http.MethodPut:     item.Put,
+		http.MethodPost:    item.Post,
+		http.MethodDelete:  item.Delete,
+		http.MethodOptions: item.Options,
+		http.MethodHead:    item.Head,
+		http.MethodPatch:   item.Patch,
+		http.MethodTrace:   item.Trace,
+	} {
+		if op != nil {
+			ops[method] = op
+		}
+	}
+	return ops
+}
+
+// setOperation sets the operation for the given request method.
+// It reports false for methods OpenAPI can't describe.
+func (item *OpenAPIPathItem) setOperation(method string, op *OpenAPIOperation) bool {
+	switch method {
+	case http.MethodGet:
+		item.Get = op
+	case http.MethodPut:
+		item.Put = op
+	case http.MethodPost:
+		item.Post = op
+	case http.MethodDelete:
+		item.Delete = op
+	case http.MethodOptions:
+		item.Options = op
+	case http.MethodHead:
+		item.Head = op
+	case http.MethodPatch:
+		item.Patch = op
+	case http.MethodTrace:
+		item.Trace = op
+	default:
+		return false
+	}
+	return true
+}
+
+// OpenAPIOperation describes a single API operation on a path.
+type OpenAPIOperation struct {
+	OperationID string                      `json:"operationId,omitempty"`
+	Summary     string                      `json:"summary,omitempty"`
+	Description string                      `json:"description,omitempty"`
+	Tags        []string                    `json:"tags,omitempty"`
+	Deprecated  bool                        `json:"deprecated,omitempty"`
+	Parameters  []*OpenAPIParameter         `json:"parameters,omitempty"`
+	RequestBody *OpenAPIRequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*OpenAPIResponse `json:"responses"`
+}
+
+// OpenAPIParameter describes a single operation parameter.
+type OpenAPIParameter struct {
+	Name        string      `json:"name"`
+	In          string      `json:"in"`
+	Description string      `json:"description,omitempty"`
+	Required    bool        `json:"required,omitempty"`
+	Schema      interface{} `json:"schema,omitempty"`
+
+	// Whether a path parameter is a catch-all, matching the remaining path
+	// including slashes. This is an extension of OpenAPI.
+	CatchAll bool `json:"x-catch-all,omitempty"`
+}
+
+// OpenAPIRequestBody describes a request body.
+type OpenAPIRequestBody struct {
+	Description string                       `json:"description,omitempty"`
+	Required    bool                         `json:"required,omitempty"`
+	Content     map[string]*OpenAPIMediaType `json:"content"`
+}
+
+// OpenAPIResponse describes a single response of an operation.
+type OpenAPIResponse struct {
+	Description string                       `json:"description"`
+	Content     map[string]*OpenAPIMediaType `json:"content,omitempty"`
+}
+
+// OpenAPIMediaType describes the body of a media type.
+type OpenAPIMediaType struct {
+	Schema interface{} `json:"schema,omitempty"`
+}
+
+// openAPIPath converts a route path to an OpenAPI path template, and returns
+// the names of its parameters. The last name is that of the catch-all, if
+// catchAll is true.
+func openAPIPath(path string) (template string, names []string, catchAll bool) {
+	normalized, wildcardNames := normalizePath(path)
+
+	var b strings.Builder
+	i := 0
+	for _, c := range []byte(normalized) {
+		if c != ':' && c != '*' {
+			b.WriteByte(c)
+			continue
+		}
+
+		name := wildcardNames[i]
+		if c == '*' {
+			catchAll = true
+			if name == "*" {
+				name = "path"
+			}
+		}
+		names = append(names, name)
+		b.WriteString("{" + name + "}")
+		i++
+	}
+	return b.String(), names, catchAll
+}
+
+// OpenAPI generates an OpenAPI 3 document describing all registered routes.
+//
+// Named parameters become path templates, like /users/{id} for /users/:id.
+// A catch-all becomes a path parameter as well, documented to contain the
+// remaining path. Operations are described by the metadata of the routes,
+// see MetaSummary and the other Meta constants. Routes registered for methods
+// OpenAPI can't describe are left out.
+func (r *Router) OpenAPI(info OpenAPIInfo) *OpenAPIDocument {
+	doc := &OpenAPIDocument{
+		OpenAPI: "3.0.3",
+		Info:    info,
+		Paths:   make(map[string]*OpenAPIPathItem),
+	}
+
+	for _, route := range r.Routes() {
+		template, names, catchAll := openAPIPath(route.Path)
+
+		op := &OpenAPIOperation{
+			Responses: map[string]*OpenAPIResponse{},
+		}
+		op.OperationID, _ = route.Value(MetaOperationID).(string)
+		op.Summary, _ = route.Value(MetaSummary).(string)
+		op.Description, _ = route.Value(MetaDescription).(string)
+		op.Tags = metaStrings(route.Value(MetaTags))
+		op.Deprecated, _ = route.Value(MetaDeprecated).(bool)
+
+		for i, name := range names {
+			param := &OpenAPIParameter{
+				Name:     name,
+				In:       "path",
+				Required: true,
+				Schema:   map[string]string{"type": "string"},
+			}
+			if catchAll && i == len(names)-1 {
+				param.Description = "The remaining path, which may contain slashes."
+				param.CatchAll = true
+			}
+			op.Parameters = append(op.Parameters, param)
+		}
+
+		if schema := route.Value(MetaRequestSchema); schema != nil {
+			op.RequestBody = &OpenAPIRequestBody{
+				Required: true,
+				Content: map[string]*OpenAPIMediaType{
+					"application/json": {Schema: schema},
+				},
+			}
+		}
+
+		response := &OpenAPIResponse{Description: http.StatusText(http.StatusOK)}
+		if schema := route.Value(MetaResponseSchema); schema != nil {
+			response.Content = map[string]*OpenAPIMediaType{
+				"application/json": {Schema: schema},
+			}
+		}
+		op.Responses["200"] = response
+
+		item := doc.Paths[template]
+		if item == nil {
+			item = &OpenAPIPathItem{}
+		}
+		if item.setOperation(route.Method, op) {
+			doc.Paths[template] = item
+		}
+	}
+	return doc
+}
+
+// JSON returns the document encoded as JSON.
+func (doc *OpenAPIDocument) JSON() ([]byte, error) {
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+// YAML returns the document encoded as YAML.
+func (doc *OpenAPIDocument) YAML() ([]byte, error) {
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	return jsonToYAML(data)
+}
+
+// OpenAPIHandler returns a handler serving the OpenAPI document of the router,
+// generated on each request so it always reflects the registered routes.
+// The document is served as YAML if the request path ends with .yaml or .yml,
+// or the client accepts YAML, and as JSON otherwise.
+func (r *Router) OpenAPIHandler(info OpenAPIInfo) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		doc := r.OpenAPI(info)
+
+		contentType := "application/json"
+		encode := doc.JSON
+		if strings.HasSuffix(req.URL.Path, ".yaml") || strings.HasSuffix(req.URL.Path, ".yml") ||
+			strings.Contains(req.Header.Get("Accept"), "yaml") {
+			contentType = "application/yaml"
+			encode = doc.YAML
+		}
+
+		body, err := encode()
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.Write(body)
+	})
+}
+
+// OpenAPIOperationRef identifies an operation of an OpenAPI document.
+type OpenAPIOperationRef struct {
+	Method      string
+	Path        string // the path of the route
+	OperationID string
+}
+
+func (ref OpenAPIOperationRef) String() string {
+	s := ref.Method + " " + ref.Path
+	if ref.OperationID != "" {
+		s += " (" + ref.OperationID + ")"
+	}
+	return s
+}
+
+// OpenAPIReport summarizes the routes registered from an OpenAPI document.
+type OpenAPIReport struct {
+	// The operations registered with a handler of the registry.
+	Implemented []OpenAPIOperationRef
+
+	// The operations without a handler, registered with a stub replying
+	// 501 (Not Implemented).
+	Missing []OpenAPIOperationRef
+
+	// The operationIds of the registry not used by any operation.
+	Unused []string
+}
+
+// String formats the report for logging at startup.
+func (rep *OpenAPIReport) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "openapi: %d operations, %d implemented, %d missing",
+		len(rep.Implemented)+len(rep.Missing), len(rep.Implemented), len(rep.Missing))
+	for _, ref := range rep.Missing {
+		b.WriteString("\n  missing: " + ref.String())
+	}
+	for _, id := range rep.Unused {
+		b.WriteString("\n  unused handler: " + id)
+	}
+	return b.String()
+}
+
+// ParseOpenAPI parses an OpenAPI 3 document in JSON.
+func ParseOpenAPI(data []byte) (*OpenAPIDocument, error) {
+	doc := new(OpenAPIDocument)
+	if err := json.Unmarshal(data, doc); err != nil {
+		return nil, fmt.Errorf("openapi: %v", err)
+	}
+	if !strings.HasPrefix(doc.OpenAPI, "3.") {
+		return nil, fmt.Errorf("openapi: unsupported version %q", doc.OpenAPI)
+	}
+	return doc, nil
+}
+
+// LoadOpenAPI reads the OpenAPI 3 document in JSON from the given file
+// and registers its operations, see RegisterOpenAPI.
+func (r *Router) LoadOpenAPI(filename string, handlers map[string]http.Handler) (*OpenAPIReport, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := ParseOpenAPI(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	return r.RegisterOpenAPI(doc, handlers)
+}
+
+// RegisterOpenAPI registers a route for every operation of the given document.
+//
+// Path templates are translated to route paths, like /users/:id for
+// /users/{id}. A path parameter marked with x-catch-all becomes a catch-all.
+// The handler of an operation is looked up by its operationId in handlers.
+// Operations without a handler are registered with a stub replying 501 (Not
+// Implemented) and listed as missing in the returned report. The operationId,
+// summary, description, tags and deprecation of an operation are attached to
+// its route as metadata.
+//
+// If a path can't be translated or its route conflicts with a registered one
+// or another operation, an error is returned and no route is registered.
+func (r *Router) RegisterOpenAPI(doc *OpenAPIDocument, handlers map[string]http.Handler) (*OpenAPIReport, error) {
+	report := new(OpenAPIReport)
+	used := make(map[string]bool)
+
+	templates := make([]string, 0, len(doc.Paths))
+	for template := range doc.Paths {
+		templates = append(templates, template)
+	}
+	sort.Strings(templates)
+
+	type route struct {
+		method, path string
+		handle       http.Handler
+		op           *OpenAPIOperation
+	}
+	var routes []route
+	scratch := New()
+	for _, template := range templates {
+		item := doc.Paths[template]
+		ops := item.Operations()
+		methods := make([]string, 0, len(ops))
+		for method := range ops {
+			methods = append(methods, method)
+		}
+		sort.Strings(methods)
+
+		for _, method := range methods {
+			op := ops[method]
+			path, err := routePath(template, append(item.Parameters, op.Parameters...))
+			if err != nil {
+				return nil, err
+			}
+			// check all routes first, so that none is registered on error
+			if err := r.CheckRoute(method, path); err != nil {
+				return nil, fmt.Errorf("openapi: %s %s: %v", method, template, err)
+			}
+			if err := scratch.tryHandler(method, path, http.NotFoundHandler()); err != nil {
+				return nil, fmt.Errorf("openapi: %s %s: %v", method, template, err)
+			}
+
+			ref := OpenAPIOperationRef{Method: method, Path: path, OperationID: op.OperationID}
+			handle := handlers[op.OperationID]
+			if op.OperationID == "" || handle == nil {
+				handle = r.notImplemented(ref)
+				report.Missing = append(report.Missing, ref)
+			} else {
+				used[op.OperationID] = true
+				report.Implemented = append(report.Implemented, ref)
+			}
+			routes = append(routes, route{method, path, handle, op})
+		}
+	}
+
+	for _, rt := range routes {
+		r.Handler(rt.method, rt.path, rt.handle, rt.op.metadata())
+	}
+	for id := range handlers {
+		if !used[id] {
+			report.Unused = append(report.Unused, id)
+		}
+	}
+	sort.Strings(report.Unused)
+	return report, nil
+}
+
+// metadata returns an option attaching the description of op to its route.
+func (op *OpenAPIOperation) metadata() RouteOption {
+	return func(rt *Route) {
+		if op.OperationID != "" {
+			WithMetadata(MetaOperationID, op.OperationID)(rt)
+		}
+		if op.Summary != "" {
+			WithMetadata(MetaSummary, op.Summary)(rt)
+		}
+		if op.Description != "" {
+			WithMetadata(MetaDescription, op.Description)(rt)
+		}
+		if len(op.Tags) > 0 {
+			WithMetadata(MetaTags, op.Tags)(rt)
+		}
+		if op.Deprecated {
+			WithMetadata(MetaDeprecated, true)(rt)
+		}
+	}
+}
+
+// notImplemented returns a stub handler for an operation without a handler.
+func (r *Router) notImplemented(ref OpenAPIOperationRef) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if r.ErrorRenderer != nil {
+			p := newProblem(req, http.StatusNotImplemented)
+			p.Route = ref.Path
+			if ref.OperationID != "" {
+				p.Detail = "operation " + ref.OperationID + " is not implemented"
+			}
+			r.ErrorRenderer(w, req, p)
+			return
+		}
+		http.Error(w, http.StatusText(http.StatusNotImplemented), http.StatusNotImplemented)
+	})
+}
+
+// routePath translates an OpenAPI path template to a route path.
+func routePath(template string, params []*OpenAPIParameter) (string, error) {
+	if strings.ContainsAny(template, ":*") {
+		return "", fmt.Errorf("openapi: unsupported characters in path %q", template)
+	}
+
+	var b strings.Builder
+	for rest := template; len(rest) > 0; {
+		start := strings.IndexByte(rest, '{')
+		if start < 0 {
+			b.WriteString(rest)
+			break
+		}
+		b.WriteString(rest[:start])
+
+		end := strings.IndexByte(rest, '}')
+		if end < start+2 {
+			return "", fmt.Errorf("openapi: malformed parameter in path %q", template)
+		}
+		name := rest[start+1 : end]
+		rest = rest[end+1:]
+		if len(rest) > 0 && rest[0] != '/' {
+			return "", errors.New("openapi: parameter {" + name + "} must end its path segment in path " + template)
+		}
+
+		wildcard := ":"
+		for _, p := range params {
+			if p.In == "path" && p.Name == name && p.CatchAll {
+				if prefix := b.String(); len(rest) > 0 || !strings.HasSuffix(prefix, "/") {
+					return "", errors.New("openapi: catch-all parameter {" + name + "} must be the last path segment in path " + template)
+				}
+				wildcard = "*"
+			}
+		}
+		b.WriteString(wildcard + name)
+	}
+	return b.String(), nil
+}
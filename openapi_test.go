@@ -0,0 +1,295 @@
+package httprouter
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestOpenAPIPath(t *testing.T) {
+	tests := []struct {
+		path     string
+		template string
+		names    []string
+		catchAll bool
+	}{
+		{"/", "/", nil, false},
+		{"/users/:id", "/users/{id}", []string{"id"}, false},
+		{"/@:username/:postId", "/@{username}/{postId}", []string{"username", "postId"}, false},
+		{"/src/*filepath", "/src/{filepath}", []string{"filepath"}, true},
+		{"/user/:name/*", "/user/{name}/{path}", []string{"name", "path"}, true},
+	}
+	for _, tt := range tests {
+		template, names, catchAll := openAPIPath(tt.path)
+		if template != tt.template || !reflect.DeepEqual(names, tt.names) || catchAll != tt.catchAll {
+			t.Errorf("openAPIPath(%q) = %q, %v, %t", tt.path, template, names, catchAll)
+		}
+	}
+}
+
+func TestRouterOpenAPI(t *testing.T) {
+	handlerFunc := func(_ http.ResponseWriter, _ *http.Request) {}
+
+	router := New()
+	router.GET("/users/:id", handlerFunc,
+		WithMetadata(MetaOperationID, "getUser"),
+		WithMetadata(MetaSummary, "Get a user"),
+		WithMetadata(MetaTags, []string{"users"}),
+		WithMetadata(MetaResponseSchema, map[string]interface{}{"type": "object"}),
+	)
+	router.PUT("/users/:id", handlerFunc,
+		WithMetadata(MetaRequestSchema, json.RawMessage(`{"$ref":"#/components/schemas/User"}`)),
+		WithMetadata(MetaDeprecated, true),
+	)
+	router.GET("/src/*filepath", handlerFunc)
+	router.Handler("PURGE", "/cache", http.HandlerFunc(handlerFunc))
+
+	doc := router.OpenAPI(OpenAPIInfo{Title: "Test", Version: "1.0"})
+	if len(doc.Paths) != 2 {
+		t.Fatalf("unexpected paths %v", doc.Paths)
+	}
+
+	item := doc.Paths["/users/{id}"]
+	if item == nil || item.Get == nil || item.Put == nil {
+		t.Fatalf("missing operations for /users/{id}: %+v", item)
+	}
+	get := item.Get
+	if get.OperationID != "getUser" || get.Summary != "Get a user" || !reflect.DeepEqual(get.Tags, []string{"users"}) {
+		t.Errorf("unexpected operation %+v", get)
+	}
+	if len(get.Parameters) != 1 || get.Parameters[0].Name != "id" || get.Parameters[0].In != "path" || !get.Parameters[0].Required {
+		t.Errorf("unexpected parameters %+v", get.Parameters)
+	}
+	if get.Responses["200"].Content["application/json"] == nil {
+		t.Errorf("missing response schema %+v", get.Responses["200"])
+	}
+	if !item.Put.Deprecated || item.Put.RequestBody == nil {
+		t.Errorf("unexpected operation %+v", item.Put)
+	}
+
+	files := doc.Paths["/src/{filepath}"].Get
+	if files == nil || files.Parameters[0].Description == "" {
+		t.Errorf("catch-all parameter not documented: %+v", files)
+	}
+
+	if ops := item.Operations(); len(ops) != 2 || ops[http.MethodGet] != get {
+		t.Errorf("unexpected operations %v", ops)
+	}
+}
+
+func TestRouterOpenAPIConfig(t *testing.T) {
+	c, err := ParseConfig([]byte(`{"routes": [{
+  "method": "GET", "path": "/users/:id", "handler": "user",
+  "metadata": {"openapi.tags": ["a", "b"], "openapi.summary": "Get a user"}
+}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	router := New()
+	if err := c.Apply(router, testRegistry(new([]string))); err != nil {
+		t.Fatal(err)
+	}
+
+	doc := router.OpenAPI(OpenAPIInfo{Title: "Test", Version: "1.0"})
+	get := doc.Paths["/users/{id}"].Get
+	if get.Summary != "Get a user" || !reflect.DeepEqual(get.Tags, []string{"a", "b"}) {
+		t.Errorf("unexpected operation %+v", get)
+	}
+}
+
+func TestRouterOpenAPIHandler(t *testing.T) {
+	router := New()
+	router.GET("/users/:id", func(_ http.ResponseWriter, _ *http.Request) {},
+		WithMetadata(MetaSummary, "Get a user"))
+	router.Handler(http.MethodGet, "/openapi.json", router.OpenAPIHandler(OpenAPIInfo{Title: "Test", Version: "1.0"}))
+	router.Handler(http.MethodGet, "/openapi.yaml", router.OpenAPIHandler(OpenAPIInfo{Title: "Test", Version: "1.0"}))
+
+	r, _ := http.NewRequest(http.MethodGet, "/openapi.json", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if got := w.Header().Get("Content-Type"); got != "application/json" {
+		t.Errorf("unexpected Content-Type %q", got)
+	}
+	var doc OpenAPIDocument
+	if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("invalid JSON document: %v", err)
+	}
+	if doc.OpenAPI != "3.0.3" || doc.Paths["/users/{id}"] == nil || doc.Paths["/openapi.json"] == nil {
+		t.Errorf("unexpected document %+v", doc)
+	}
+
+	r, _ = http.NewRequest(http.MethodGet, "/openapi.yaml", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if got := w.Header().Get("Content-Type"); got != "application/yaml" {
+		t.Errorf("unexpected Content-Type %q", got)
+	}
+	if body := w.Body.String(); !strings.Contains(body, "\n  \"/users/{id}\":\n    get:\n") {
+		t.Errorf("unexpected YAML document:\n%s", body)
+	}
+}
+
+func TestRouterLoadOpenAPI(t *testing.T) {
+	var served string
+	handler := func(id string) http.Handler {
+		return http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {
+			served = id
+		})
+	}
+
+	router := New()
+	report, err := router.LoadOpenAPI("testdata/openapi.json", map[string]http.Handler{
+		"listPets":    handler("listPets"),
+		"showPetById": handler("showPetById"),
+		"deletePet":   handler("deletePet"),
+		"updatePet":   handler("updatePet"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var missing []string
+	for _, ref := range report.Missing {
+		missing = append(missing, ref.String())
+	}
+	if want := []string{"POST /pets (createPet)", "GET /static/*path"}; !reflect.DeepEqual(missing, want) {
+		t.Errorf("unexpected missing operations %v, want %v", missing, want)
+	}
+	if len(report.Implemented) != 3 {
+		t.Errorf("unexpected implemented operations %v", report.Implemented)
+	}
+	if want := []string{"updatePet"}; !reflect.DeepEqual(report.Unused, want) {
+		t.Errorf("unexpected unused handlers %v", report.Unused)
+	}
+	if s := report.String(); !strings.HasPrefix(s, "openapi: 5 operations, 3 implemented, 2 missing\n  missing: POST /pets (createPet)") {
+		t.Errorf("unexpected report:\n%s", s)
+	}
+
+	testRoutes := []struct {
+		method string
+		path   string
+		served string
+		code   int
+	}{
+		{http.MethodGet, "/pets", "listPets", http.StatusOK},
+		{http.MethodGet, "/pets/42", "showPetById", http.StatusOK},
+		{http.MethodDelete, "/pets/42", "deletePet", http.StatusOK},
+		{http.MethodPost, "/pets", "", http.StatusNotImplemented},
+		{http.MethodGet, "/static/css/site.css", "", http.StatusNotImplemented},
+	}
+	for _, tr := range testRoutes {
+		served = ""
+		r, _ := http.NewRequest(tr.method, tr.path, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if served != tr.served || w.Code != tr.code {
+			t.Errorf("%s %s: served %q with %d", tr.method, tr.path, served, w.Code)
+		}
+	}
+
+	route, _, _ := router.Lookup(http.MethodDelete, "/pets/42")
+	if route.Value(MetaOperationID) != "deletePet" || route.Value(MetaDeprecated) != true {
+		t.Errorf("unexpected metadata %v", route.Metadata)
+	}
+	route, _, _ = router.Lookup(http.MethodGet, "/pets")
+	if route.Value(MetaSummary) != "List all pets" || !reflect.DeepEqual(route.Value(MetaTags), []string{"pets"}) {
+		t.Errorf("unexpected metadata %v", route.Metadata)
+	}
+}
+
+func TestRouterOpenAPIRoundTrip(t *testing.T) {
+	handlerFunc := func(_ http.ResponseWriter, _ *http.Request) {}
+
+	router := New()
+	router.GET("/users/:id", handlerFunc, WithMetadata(MetaOperationID, "getUser"))
+	router.POST("/users", handlerFunc, WithMetadata(MetaOperationID, "createUser"))
+	router.GET("/src/*filepath", handlerFunc)
+
+	data, err := json.Marshal(router.OpenAPI(OpenAPIInfo{Title: "Test", Version: "1.0"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := ParseOpenAPI(data)
+	if err != nil {
+		t.Fatalf("%v in:\n%s", err, data)
+	}
+
+	loaded := New()
+	if _, err := loaded.RegisterOpenAPI(doc, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	var want, got []string
+	for _, route := range router.Routes() {
+		want = append(want, route.Method+" "+route.Path)
+	}
+	for _, route := range loaded.Routes() {
+		got = append(got, route.Method+" "+route.Path)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected routes %v, want %v", got, want)
+	}
+}
+
+func TestRoutePath(t *testing.T) {
+	catchAll := []*OpenAPIParameter{{Name: "rest", In: "path", CatchAll: true}}
+	tests := []struct {
+		template string
+		params   []*OpenAPIParameter
+		path     string
+		err      bool
+	}{
+		{"/", nil, "/", false},
+		{"/users/{id}", nil, "/users/:id", false},
+		{"/@{username}/{postId}", nil, "/@:username/:postId", false},
+		{"/files/{rest}", catchAll, "/files/*rest", false},
+		{"/files/{name}.json", nil, "", true},
+		{"/files/{rest}/x", catchAll, "", true},
+		{"/files/x{rest}", catchAll, "", true},
+		{"/users/{}", nil, "", true},
+		{"/users/{id", nil, "", true},
+		{"/users/:id", nil, "", true},
+	}
+	for _, tt := range tests {
+		path, err := routePath(tt.template, tt.params)
+		if path != tt.path || (err != nil) != tt.err {
+			t.Errorf("routePath(%q) = %q, %v", tt.template, path, err)
+		}
+	}
+}
+
+func TestRouterRegisterOpenAPIConflict(t *testing.T) {
+	doc, err := ParseOpenAPI([]byte(`{"openapi":"3.0.0","info":{"title":"t","version":"1"},` +
+		`"paths":{"/a/{x}":{"get":{}},"/a/{y}":{"get":{}}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := New().RegisterOpenAPI(doc, nil); err == nil {
+		t.Error("conflicting paths did not fail")
+	}
+
+	if _, err := ParseOpenAPI([]byte(`{"swagger":"2.0"}`)); err == nil {
+		t.Error("Swagger 2 document did not fail")
+	}
+}
+
+func TestRouterRegisterOpenAPIAtomic(t *testing.T) {
+	doc, err := ParseOpenAPI([]byte(`{"openapi":"3.0.0","info":{"title":"t","version":"1"},` +
+		`"paths":{"/a":{"get":{}},"/b":{"get":{}},"/c":{"get":{}}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	router := New()
+	router.GET("/b", func(_ http.ResponseWriter, _ *http.Request) {})
+	if _, err := router.RegisterOpenAPI(doc, nil); err == nil || !strings.Contains(err.Error(), "GET /b") {
+		t.Errorf("unexpected error %v", err)
+	}
+	for _, path := range []string{"/a", "/c"} {
+		if rt, _, _ := router.Lookup(http.MethodGet, path); rt != nil {
+			t.Errorf("route %s registered despite the conflict", path)
+		}
+	}
+}
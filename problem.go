@@ -0,0 +1,109 @@
+package httprouter
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Problem describes a response generated by the router itself, like a 404 or
+// 405 reply, in the shape of an RFC 7807 problem details object.
+type Problem struct {
+	// A URI reference identifying the problem type. If empty, "about:blank"
+	// is implied.
+	Type string `json:"type,omitempty"`
+
+	// A short summary of the problem type, the status text by default.
+	Title string `json:"title"`
+
+	// The HTTP status code of the response.
+	Status int `json:"status"`
+
+	// An explanation specific to this occurrence of the problem.
+	Detail string `json:"detail,omitempty"`
+
+	// The path of the request which caused the problem.
+	Instance string `json:"instance,omitempty"`
+
+	// The route matched by the request, if any.
+	Route string `json:"route,omitempty"`
+
+	// The methods allowed for the requested path, if the method was not.
+	Allowed []string `json:"allowed,omitempty"`
+
+	// The target of a redirect.
+	Location string `json:"location,omitempty"`
+}
+
+// newProblem returns a problem with the given status for req.
+func newProblem(req *http.Request, status int) *Problem {
+	return &Problem{
+		Title:    http.StatusText(status),
+		Status:   status,
+		Instance: req.URL.Path,
+	}
+}
+
+// RenderProblem writes p as an application/problem+json document if the
+// client accepts JSON, and as plain text otherwise.
+// It can be used as Router.ErrorRenderer.
+func RenderProblem(w http.ResponseWriter, req *http.Request, p *Problem) {
+	if !acceptsJSON(req.Header.Get("Accept")) {
+		text := p.Title
+		if p.Detail != "" {
+			text += ": " + p.Detail
+		}
+		http.Error(w, text, p.Status)
+		return
+	}
+
+	body, err := json.Marshal(p)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	header := w.Header()
+	header.Set("Content-Type", "application/problem+json")
+	header.Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(p.Status)
+	w.Write(append(body, '\n'))
+}
+
+// acceptsJSON reports whether the given Accept header prefers a JSON media
+// type over a text one. Wildcards like */* are regarded as neither, so
+// clients not asking for JSON explicitly get plain text.
+func acceptsJSON(accept string) bool {
+	if accept == "" {
+		return false
+	}
+
+	var jsonQ, textQ float64
+	for _, mediaRange := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(mediaRange)
+		if err != nil {
+			continue
+		}
+
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+
+		switch {
+		case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+			if q > jsonQ {
+				jsonQ = q
+			}
+		case strings.HasPrefix(mediaType, "text/"):
+			if q > textQ {
+				textQ = q
+			}
+		}
+	}
+	return jsonQ > 0 && jsonQ >= textQ
+}
@@ -0,0 +1,154 @@
+package httprouter
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAcceptsJSON(t *testing.T) {
+	tests := []struct {
+		accept string
+		json   bool
+	}{
+		{"", false},
+		{"*/*", false},
+		{"text/html", false},
+		{"application/json", true},
+		{"application/problem+json", true},
+		{"text/plain, application/json", true},
+		{"text/plain, application/json;q=0.5", false},
+		{"text/html;q=0.9, application/problem+json", true},
+		{"application/json;q=0", false},
+		{"application/json;q=bogus", false},
+	}
+	for _, tt := range tests {
+		if got := acceptsJSON(tt.accept); got != tt.json {
+			t.Errorf("acceptsJSON(%q) = %t, want %t", tt.accept, got, tt.json)
+		}
+	}
+}
+
+func TestRenderProblem(t *testing.T) {
+	p := &Problem{
+		Title:    "Method Not Allowed",
+		Status:   http.StatusMethodNotAllowed,
+		Instance: "/path",
+		Allowed:  []string{"GET", "OPTIONS"},
+	}
+
+	r, _ := http.NewRequest(http.MethodPost, "/path", nil)
+	r.Header.Set("Accept", "application/json")
+	w := httptest.NewRecorder()
+	RenderProblem(w, r, p)
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("unexpected status %d", w.Code)
+	}
+	if got := w.Header().Get("Content-Type"); got != "application/problem+json" {
+		t.Errorf("unexpected Content-Type %q", got)
+	}
+	var got Problem
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatalf("invalid JSON body %q: %v", w.Body.String(), err)
+	}
+	if !reflect.DeepEqual(&got, p) {
+		t.Errorf("unexpected problem %+v, want %+v", got, p)
+	}
+
+	r.Header.Set("Accept", "text/plain")
+	w = httptest.NewRecorder()
+	p.Detail = "try GET"
+	RenderProblem(w, r, p)
+	if got := w.Header().Get("Content-Type"); !strings.HasPrefix(got, "text/plain") {
+		t.Errorf("unexpected Content-Type %q", got)
+	}
+	if got := w.Body.String(); got != "Method Not Allowed: try GET\n" {
+		t.Errorf("unexpected body %q", got)
+	}
+}
+
+func TestRouterErrorRenderer(t *testing.T) {
+	handlerFunc := func(_ http.ResponseWriter, _ *http.Request) {}
+
+	var rendered *Problem
+	router := New()
+	router.ErrorLog = log.New(ioutil.Discard, "", 0)
+	router.ErrorRenderer = func(w http.ResponseWriter, req *http.Request, p *Problem) {
+		rendered = p
+		RenderProblem(w, req, p)
+	}
+	router.POST("/path", handlerFunc)
+	router.GET("/dir/", handlerFunc)
+	router.GET("/panic/:id", func(_ http.ResponseWriter, _ *http.Request) {
+		panic("oops!")
+	})
+
+	testRoutes := []struct {
+		method  string
+		path    string
+		problem Problem
+	}{
+		{http.MethodGet, "/nope", Problem{
+			Title:    "Not Found",
+			Status:   http.StatusNotFound,
+			Instance: "/nope",
+		}},
+		{http.MethodGet, "/path", Problem{
+			Title:    "Method Not Allowed",
+			Status:   http.StatusMethodNotAllowed,
+			Instance: "/path",
+			Allowed:  []string{"OPTIONS", "POST"},
+		}},
+		{http.MethodGet, "/dir", Problem{
+			Title:    "Moved Permanently",
+			Status:   http.StatusMovedPermanently,
+			Instance: "/dir",
+			Route:    "/dir/",
+			Location: "/dir/",
+		}},
+		{http.MethodGet, "/panic/1", Problem{
+			Title:    "Internal Server Error",
+			Status:   http.StatusInternalServerError,
+			Instance: "/panic/1",
+			Route:    "/panic/:id",
+		}},
+	}
+	for _, tr := range testRoutes {
+		rendered = nil
+		r, _ := http.NewRequest(tr.method, tr.path, nil)
+		r.Header.Set("Accept", "application/problem+json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+
+		if rendered == nil {
+			t.Errorf("%s %s: no problem rendered", tr.method, tr.path)
+			continue
+		}
+		if !reflect.DeepEqual(*rendered, tr.problem) {
+			t.Errorf("%s %s: unexpected problem %+v, want %+v", tr.method, tr.path, *rendered, tr.problem)
+		}
+		if w.Code != tr.problem.Status {
+			t.Errorf("%s %s: unexpected status %d", tr.method, tr.path, w.Code)
+		}
+		if got := w.Header().Get("Location"); got != tr.problem.Location {
+			t.Errorf("%s %s: unexpected Location %q", tr.method, tr.path, got)
+		}
+	}
+
+	// more specific handlers take priority
+	var notFound bool
+	router.NotFound = http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {
+		notFound = true
+	})
+	rendered = nil
+	r, _ := http.NewRequest(http.MethodGet, "/nope", nil)
+	router.ServeHTTP(httptest.NewRecorder(), r)
+	if !notFound || rendered != nil {
+		t.Error("NotFound handler was not preferred over the error renderer")
+	}
+}
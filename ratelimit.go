@@ -0,0 +1,295 @@
+package httprouter
+
+import (
+	"container/list"
+	"errors"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MetaRateLimit is the metadata key under which the rate limit of a route is
+// stored, see WithRateLimit. The value is a Rate or a string understood by
+// ParseRate, e.g. in route configs. Strings are parsed when the route is
+// registered.
+const MetaRateLimit = "httprouter.rateLimit"
+
+// Rate is the rate of requests a client may make to a route.
+type Rate struct {
+	// The number of requests allowed per period.
+	Requests int
+	Per      time.Duration
+
+	// The number of requests allowed in a burst. If 0, Requests is used.
+	Burst int
+}
+
+// ParseRate parses a rate like "10/s", "100/m", "1000/h" or "5/30s".
+func ParseRate(s string) (Rate, error) {
+	slash := strings.IndexByte(s, '/')
+	if slash < 0 {
+		return Rate{}, errors.New("httprouter: invalid rate " + strconv.Quote(s))
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(s[:slash]))
+	if err != nil || n <= 0 {
+		return Rate{}, errors.New("httprouter: invalid rate " + strconv.Quote(s))
+	}
+
+	var per time.Duration
+	switch unit := strings.TrimSpace(s[slash+1:]); unit {
+	case "s":
+		per = time.Second
+	case "m":
+		per = time.Minute
+	case "h":
+		per = time.Hour
+	default:
+		if per, err = time.ParseDuration(unit); err != nil || per <= 0 {
+			return Rate{}, errors.New("httprouter: invalid rate " + strconv.Quote(s))
+		}
+	}
+	return Rate{Requests: n, Per: per}, nil
+}
+
+// WithRateLimit limits the rate of requests each client may make to a route,
+// see RateLimiter.
+func WithRateLimit(rate Rate) RouteOption {
+	return WithMetadata(MetaRateLimit, rate)
+}
+
+// routeRate returns the rate limit of a route, if any.
+func routeRate(rt *Route) (Rate, bool) {
+	switch v := rt.Value(MetaRateLimit).(type) {
+	case Rate:
+		return v, v.Requests > 0 && v.Per > 0
+	case *Rate:
+		if v != nil {
+			return *v, v.Requests > 0 && v.Per > 0
+		}
+	}
+	return Rate{}, false
+}
+
+// parseRateLimit replaces a rate limit given as a string in the metadata of a
+// route by the parsed Rate, so that it isn't parsed for every request.
+func parseRateLimit(rt *Route) error {
+	s, ok := rt.Metadata[MetaRateLimit].(string)
+	if !ok {
+		return nil
+	}
+	rate, err := ParseRate(s)
+	if err != nil {
+		return err
+	}
+	rt.Metadata[MetaRateLimit] = rate
+	return nil
+}
+
+// checkRateLimit checks a rate limit given as a string in m.
+func checkRateLimit(m Metadata) error {
+	if s, ok := m[MetaRateLimit].(string); ok {
+		_, err := ParseRate(s)
+		return err
+	}
+	return nil
+}
+
+// RateLimiter enforces the rate limits of routes with token buckets. Every
+// client has its own bucket per route, identified by the route path and the
+// key returned by Key.
+//
+// Rate limits are set per route with WithRateLimit, or for all routes of a
+// group in its Metadata:
+//
+//	api := router.Group("/api")
+//	api.Metadata = httprouter.Metadata{httprouter.MetaRateLimit: httprouter.Rate{Requests: 100, Per: time.Minute}}
+//	router.POST("/login", login, httprouter.WithRateLimit(httprouter.Rate{Requests: 5, Per: time.Minute}))
+//
+//	limiter := &httprouter.RateLimiter{Router: router}
+//	log.Fatal(http.ListenAndServe(":8080", limiter.Middleware(router)))
+//
+// Requests exceeding the limit are answered with 429 (Too Many Requests) and a
+// Retry-After header. All limited responses carry the RateLimit-Limit,
+// RateLimit-Remaining and RateLimit-Reset headers.
+//
+// At most MaxBuckets buckets are kept. Buckets which are full again are
+// dropped, since they behave like missing ones; if there are still too many,
+// the least recently used are. A dropped bucket starts over full, so clients
+// able to make up new keys at will can evade the limits. Keys must therefore
+// come from a trusted source, see Key.
+type RateLimiter struct {
+	// The router to look up the route of a request in, if the middleware
+	// wraps the router. If the middleware wraps the handlers of routes, the
+	// matched route is taken from the request context instead.
+	// Its ErrorRenderer, if set, renders the 429 replies.
+	Router *Router
+
+	// Returns the key identifying the client of a request, like an API key.
+	// If nil, ClientIP is used.
+	// The key must be one the client can't choose freely, like the IP
+	// address of a direct connection, an authenticated user or an API key
+	// verified before the middleware runs.
+	Key func(*http.Request) string
+
+	// The maximum number of buckets kept. If 0, DefaultMaxBuckets is used.
+	MaxBuckets int
+
+	mu      sync.Mutex
+	buckets map[string]*list.Element
+	lru     list.List        // of *bucket, the most recently used first
+	now     func() time.Time // for tests
+}
+
+// DefaultMaxBuckets is the number of buckets kept by a RateLimiter without
+// MaxBuckets.
+const DefaultMaxBuckets = 1 << 16
+
+type bucket struct {
+	key    string
+	tokens float64
+	last   time.Time
+	full   time.Time // when the bucket is full again
+}
+
+// ClientIP returns the IP address of the client of a request, without the
+// port.
+func ClientIP(req *http.Request) string {
+	if host, _, err := net.SplitHostPort(req.RemoteAddr); err == nil {
+		return host
+	}
+	return req.RemoteAddr
+}
+
+// KeyByHeader returns a key function for RateLimiter identifying clients by
+// the given request header, like an API key, or by their IP address if the
+// header is missing.
+//
+// The header is not verified, so clients sending a different value with
+// every request are never limited. Only use it for headers set by a trusted
+// proxy, or verified by an authenticating middleware running before the
+// rate limiter.
+func KeyByHeader(name string) func(*http.Request) string {
+	return func(req *http.Request) string {
+		if key := req.Header.Get(name); key != "" {
+			return "h:" + key
+		}
+		return "ip:" + ClientIP(req)
+	}
+}
+
+// Middleware returns a handler enforcing the rate limits of the routes
+// served by next.
+func (l *RateLimiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		rt := RouteFromContext(req.Context())
+		if rt == nil && l.Router != nil {
+			path := req.URL.Path
+			if l.Router.UseRawPath && len(req.URL.RawPath) > 0 {
+				path = req.URL.RawPath
+			}
+			rt, _, _ = l.Router.Lookup(req.Method, path)
+		}
+		rate, ok := routeRate(rt)
+		if !ok {
+			next.ServeHTTP(w, req)
+			return
+		}
+
+		key := l.Key
+		if key == nil {
+			key = ClientIP
+		}
+		allowed, remaining, reset, retry := l.take(rt.Method+" "+rt.Path+"\x00"+key(req), rate)
+
+		header := w.Header()
+		header.Set("RateLimit-Limit", strconv.Itoa(rate.Requests))
+		header.Set("RateLimit-Remaining", strconv.Itoa(remaining))
+		header.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(reset)))
+		if allowed {
+			next.ServeHTTP(w, req)
+			return
+		}
+
+		header.Set("Retry-After", strconv.Itoa(ceilSeconds(retry)))
+		if l.Router != nil && l.Router.ErrorRenderer != nil {
+			p := newProblem(req, http.StatusTooManyRequests)
+			p.Route = rt.Path
+			l.Router.ErrorRenderer(w, req, p)
+		} else {
+			http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+		}
+	})
+}
+
+// take takes a token from the bucket stored under key. It returns whether
+// there was one, the number of tokens left, the time until the bucket is full
+// and the time until the next token is available.
+func (l *RateLimiter) take(key string, rate Rate) (allowed bool, remaining int, reset, retry time.Duration) {
+	burst := rate.Burst
+	if burst <= 0 {
+		burst = rate.Requests
+	}
+	interval := rate.Per / time.Duration(rate.Requests) // per token
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if l.now != nil {
+		now = l.now()
+	}
+	l.evict(now)
+
+	var b *bucket
+	if e := l.buckets[key]; e != nil {
+		b = e.Value.(*bucket)
+		l.lru.MoveToFront(e)
+	} else {
+		b = &bucket{key: key, tokens: float64(burst), last: now}
+		l.buckets[key] = l.lru.PushFront(b)
+		max := l.MaxBuckets
+		if max <= 0 {
+			max = DefaultMaxBuckets
+		}
+		for l.lru.Len() > max {
+			l.remove(l.lru.Back())
+		}
+	}
+	b.tokens = math.Min(float64(burst), b.tokens+float64(now.Sub(b.last))/float64(interval))
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		allowed = true
+	} else {
+		retry = time.Duration((1 - b.tokens) * float64(interval))
+	}
+	reset = time.Duration((float64(burst) - b.tokens) * float64(interval))
+	b.full = now.Add(reset)
+	return allowed, int(b.tokens), reset, retry
+}
+
+// evict drops the least recently used buckets which are full again. Since a
+// full bucket behaves like a missing one, only the buckets of recently active
+// clients are kept. Every bucket is dropped once at most, so evicting costs
+// constant time per request on average.
+func (l *RateLimiter) evict(now time.Time) {
+	if l.buckets == nil {
+		l.buckets = make(map[string]*list.Element)
+	}
+	for e := l.lru.Back(); e != nil && !now.Before(e.Value.(*bucket).full); e = l.lru.Back() {
+		l.remove(e)
+	}
+}
+
+func (l *RateLimiter) remove(e *list.Element) {
+	delete(l.buckets, l.lru.Remove(e).(*bucket).key)
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,164 @@
+package httprouter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestParseRate(t *testing.T) {
+	tests := []struct {
+		s    string
+		rate Rate
+		ok   bool
+	}{
+		{"10/s", Rate{Requests: 10, Per: time.Second}, true},
+		{"100/m", Rate{Requests: 100, Per: time.Minute}, true},
+		{"1000 / h", Rate{Requests: 1000, Per: time.Hour}, true},
+		{"5/30s", Rate{Requests: 5, Per: 30 * time.Second}, true},
+		{"5", Rate{}, false},
+		{"0/s", Rate{}, false},
+		{"5/d", Rate{}, false},
+		{"5/-1s", Rate{}, false},
+	}
+	for _, tt := range tests {
+		rate, err := ParseRate(tt.s)
+		if (err == nil) != tt.ok || rate != tt.rate {
+			t.Errorf("ParseRate(%q) = %v, %v", tt.s, rate, err)
+		}
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	router := New()
+	ok := func(w http.ResponseWriter, req *http.Request) {}
+	router.POST("/login", ok, WithRateLimit(Rate{Requests: 2, Per: time.Minute}))
+	router.GET("/free", ok)
+	api := router.Group("/api")
+	api.Metadata = Metadata{MetaRateLimit: "1/s"}
+	api.GET("/search", ok)
+
+	limiter := &RateLimiter{Router: router, Key: KeyByHeader("X-API-Key"), now: func() time.Time { return now }}
+	handler := limiter.Middleware(router)
+
+	serve := func(method, path, key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		if key != "" {
+			req.Header.Set("X-API-Key", key)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	for i, want := range []struct {
+		code      int
+		remaining string
+	}{
+		{http.StatusOK, "1"},
+		{http.StatusOK, "0"},
+		{http.StatusTooManyRequests, "0"},
+	} {
+		w := serve(http.MethodPost, "/login", "")
+		if w.Code != want.code || w.Header().Get("RateLimit-Remaining") != want.remaining ||
+			w.Header().Get("RateLimit-Limit") != "2" {
+			t.Errorf("request %d: unexpected status %d and headers %v", i, w.Code, w.Header())
+		}
+	}
+	w := serve(http.MethodPost, "/login", "")
+	if retry := w.Header().Get("Retry-After"); retry != "30" {
+		t.Errorf("unexpected Retry-After %q", retry)
+	}
+	if reset := w.Header().Get("RateLimit-Reset"); reset != "60" {
+		t.Errorf("unexpected RateLimit-Reset %q", reset)
+	}
+
+	// other clients and routes have their own buckets
+	if w := serve(http.MethodPost, "/login", "key"); w.Code != http.StatusOK {
+		t.Errorf("other client was limited: %d", w.Code)
+	}
+	if w := serve(http.MethodGet, "/api/search", ""); w.Code != http.StatusOK {
+		t.Errorf("other route was limited: %d", w.Code)
+	}
+	if w := serve(http.MethodGet, "/api/search", ""); w.Code != http.StatusTooManyRequests {
+		t.Errorf("group rate limit not enforced: %d", w.Code)
+	}
+	for i := 0; i < 5; i++ {
+		if w := serve(http.MethodGet, "/free", ""); w.Code != http.StatusOK || w.Header().Get("RateLimit-Limit") != "" {
+			t.Errorf("route without rate limit was limited: %d", w.Code)
+		}
+	}
+
+	// tokens are refilled over time
+	now = now.Add(30 * time.Second)
+	if w := serve(http.MethodPost, "/login", ""); w.Code != http.StatusOK {
+		t.Errorf("bucket was not refilled: %d", w.Code)
+	}
+
+	// full buckets are evicted
+	now = now.Add(2 * time.Minute)
+	serve(http.MethodGet, "/free", "")
+	limiter.take("evict", Rate{Requests: 1, Per: time.Second})
+	if n := len(limiter.buckets); n != 1 {
+		t.Errorf("%d buckets left after eviction, want 1", n)
+	}
+}
+
+func TestRateLimiterMaxBuckets(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	limiter := &RateLimiter{MaxBuckets: 2, now: func() time.Time { return now }}
+	rate := Rate{Requests: 1, Per: time.Hour}
+
+	limiter.take("a", rate)
+	limiter.take("b", rate)
+	if allowed, _, _, _ := limiter.take("a", rate); allowed {
+		t.Error("a was not limited")
+	}
+	limiter.take("c", rate) // evicts b, the least recently used
+	if n := len(limiter.buckets); n != 2 || limiter.lru.Len() != 2 {
+		t.Errorf("%d buckets kept, want 2", n)
+	}
+	if allowed, _, _, _ := limiter.take("a", rate); allowed {
+		t.Error("a was evicted")
+	}
+	if allowed, _, _, _ := limiter.take("b", rate); !allowed {
+		t.Error("b was not evicted")
+	}
+}
+
+func TestRateLimitParsedAtRegistration(t *testing.T) {
+	router := New()
+	router.GET("/a", func(http.ResponseWriter, *http.Request) {}, WithMetadata(MetaRateLimit, "5/m"))
+	rt, _, _ := router.Lookup(http.MethodGet, "/a")
+	if rate, ok := rt.Value(MetaRateLimit).(Rate); !ok || rate != (Rate{Requests: 5, Per: time.Minute}) {
+		t.Errorf("unexpected rate %#v", rt.Value(MetaRateLimit))
+	}
+
+	recv := catchPanic(func() {
+		router.GET("/b", func(http.ResponseWriter, *http.Request) {}, WithMetadata(MetaRateLimit, "5/fortnight"))
+	})
+	if recv == nil {
+		t.Error("invalid rate did not panic")
+	}
+}
+
+func TestRateLimiterRouteMiddleware(t *testing.T) {
+	limiter := &RateLimiter{}
+	router := New()
+	router.ErrorRenderer = RenderProblem
+	router.Handler(http.MethodGet, "/search", limiter.Middleware(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {})),
+		WithRateLimit(Rate{Requests: 1, Per: time.Hour}))
+
+	codes := []int{}
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/search", nil))
+		codes = append(codes, w.Code)
+	}
+	if codes[0] != http.StatusOK || codes[1] != http.StatusTooManyRequests {
+		t.Errorf("unexpected status codes %v", codes)
+	}
+}
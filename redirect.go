@@ -0,0 +1,289 @@
+package httprouter
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// MetaRedirect is the metadata key under which the target of a redirect rule
+// is stored on its route, see Router.Redirect.
+const MetaRedirect = "httprouter.redirect"
+
+// RedirectRule redirects the requests matching a route to a target built from
+// the parameters of the route.
+type RedirectRule struct {
+	Method string
+	Path   string
+
+	// The target of the redirect, like "/new/{id}/{rest}". Each {name} is
+	// replaced by the value of the named parameter of Path, {*} by the value
+	// of an unnamed catch-all.
+	Target string
+
+	// The status code of the redirect. If 0, 301 (Moved Permanently) is used.
+	Code int
+
+	// The line of the rule in the table it was parsed from, if any.
+	Line int
+}
+
+func (rule *RedirectRule) String() string {
+	s := rule.Method + " " + rule.Path + " -> " + rule.Target
+	if rule.Code != 0 {
+		s += " " + strconv.Itoa(rule.Code)
+	}
+	return s
+}
+
+// ParseRedirectRules parses a table of redirect rules, one per line:
+//
+//	# legacy articles
+//	GET /old/:id/*rest -> /new/{id}/{rest} 301
+//	GET /blog/*        -> https://blog.example.com/{*} 302
+//
+// Blank lines and lines starting with '#' are skipped. The status code is
+// optional.
+func ParseRedirectRules(data []byte) ([]*RedirectRule, error) {
+	var rules []*RedirectRule
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || text[0] == '#' {
+			continue
+		}
+
+		fields := strings.Fields(text)
+		if len(fields) < 4 || len(fields) > 5 || fields[2] != "->" {
+			return nil, fmt.Errorf("httprouter: line %d: invalid redirect rule %q", line, text)
+		}
+		rule := &RedirectRule{Method: fields[0], Path: fields[1], Target: fields[3], Line: line}
+		if len(fields) == 5 {
+			code, err := strconv.Atoi(fields[4])
+			if err != nil || !isRedirectCode(code) {
+				return nil, fmt.Errorf("httprouter: line %d: invalid redirect code %q", line, fields[4])
+			}
+			rule.Code = code
+		}
+		rules = append(rules, rule)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// Redirect registers a route redirecting the requests matching the given
+// method and path to target, see RedirectRule. Redirect rules are routes
+// like any other, so they are matched as fast and conflict with the routes of
+// the same path.
+//
+// The values of the parameters are escaped into the target, so that e.g. an
+// escaped slash in a parameter stays escaped. The query of the request is
+// appended to that of the target. Targets starting with '/' are resolved
+// below the prefix the router is mounted at, if any, and made absolute URLs
+// if RedirectBaseURL is set.
+func (r *Router) Redirect(method, path, target string, code int, opts ...RouteOption) {
+	rr, err := r.newRedirectRule(path, target, code)
+	if err != nil {
+		panic(err.Error())
+	}
+	opts = append([]RouteOption{WithMetadata(MetaRedirect, target)}, opts...)
+	r.Handler(method, path, rr, opts...)
+}
+
+// newRedirectRule returns the handle of a redirect rule.
+func (r *Router) newRedirectRule(path, target string, code int) (*redirectRule, error) {
+	if code == 0 {
+		code = http.StatusMovedPermanently
+	}
+	if !isRedirectCode(code) {
+		return nil, errors.New("invalid redirect code " + strconv.Itoa(code) + " for path '" + path + "'")
+	}
+	if len(path) < 1 || path[0] != '/' {
+		return nil, errors.New("path must begin with '/' in path '" + path + "'")
+	}
+	normalized, names := normalizePath(path)
+	parts, err := parseTarget(target, names, strings.HasSuffix(normalized, "*"))
+	if err != nil {
+		return nil, errors.New(err.Error() + " in target '" + target + "' of path '" + path + "'")
+	}
+	return &redirectRule{router: r, code: code, parts: parts, absolute: target[0] != '/'}, nil
+}
+
+// AddRedirectRules registers the given redirect rules, see Redirect.
+//
+// Every rule is checked before any is registered: malformed paths and
+// targets, and conflicts with registered routes or other rules are reported
+// with the line of the rule, if known. In that case no rule is registered.
+func (r *Router) AddRedirectRules(rules []*RedirectRule) error {
+	handles := make([]*redirectRule, len(rules))
+	scratch := New()
+	for i, rule := range rules {
+		rr, err := r.checkRedirectRule(rule, scratch)
+		if err != nil {
+			if rule.Line > 0 {
+				return fmt.Errorf("httprouter: line %d: %s: %v", rule.Line, rule, err)
+			}
+			return fmt.Errorf("httprouter: %s: %v", rule, err)
+		}
+		handles[i] = rr
+	}
+
+	for i, rule := range rules {
+		r.Handler(rule.Method, rule.Path, handles[i], WithMetadata(MetaRedirect, rule.Target))
+	}
+	return nil
+}
+
+// checkRedirectRule checks whether a rule could be registered on r, next to
+// the rules already registered on scratch, and returns its handle.
+func (r *Router) checkRedirectRule(rule *RedirectRule, scratch *Router) (*redirectRule, error) {
+	if err := r.CheckRoute(rule.Method, rule.Path); err != nil {
+		return nil, err
+	}
+	if err := scratch.tryHandler(rule.Method, rule.Path, http.NotFoundHandler()); err != nil {
+		return nil, err
+	}
+	return r.newRedirectRule(rule.Path, rule.Target, rule.Code)
+}
+
+func isRedirectCode(code int) bool {
+	switch code {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther,
+		http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return true
+	}
+	return false
+}
+
+// targetPart is a literal or a parameter of a redirect target.
+type targetPart struct {
+	literal  string
+	param    string // the key of the parameter in Params
+	catchAll bool
+	query    bool // if the part is in the query of the target
+}
+
+// parseTarget splits a redirect target into literals and the parameters, all
+// of which must be among the given wildcard names of the path. The last of
+// them is a catch-all if catchAll is set.
+func parseTarget(target string, names []string, catchAll bool) ([]targetPart, error) {
+	if target == "" || target[0] != '/' && !strings.Contains(target, "://") {
+		return nil, errors.New("target must begin with '/' or be an absolute URL")
+	}
+
+	var parts []targetPart
+	query := false
+	for target != "" {
+		open := strings.IndexByte(target, '{')
+		if open < 0 {
+			parts = append(parts, targetPart{literal: target, query: query})
+			break
+		}
+		if open > 0 {
+			parts = append(parts, targetPart{literal: target[:open], query: query})
+			query = query || strings.IndexByte(target[:open], '?') >= 0
+		}
+		end := strings.IndexByte(target[open:], '}')
+		if end < 0 {
+			return nil, errors.New("unclosed '{'")
+		}
+		name := target[open+1 : open+end]
+
+		part := targetPart{query: query}
+		for i, wildcard := range names {
+			if wildcard != name {
+				continue
+			}
+			part.param = name
+			part.catchAll = catchAll && i == len(names)-1
+			break
+		}
+		if part.param == "" {
+			return nil, errors.New("unknown parameter {" + name + "}")
+		}
+		if name == "*" {
+			part.param = catchAllParam
+		}
+		parts = append(parts, part)
+		target = target[open+end+1:]
+	}
+	return parts, nil
+}
+
+// redirectRule is the handle of a route registered by Router.Redirect.
+type redirectRule struct {
+	router   *Router
+	code     int
+	parts    []targetPart
+	absolute bool // if the target is an absolute URL
+}
+
+func (rr *redirectRule) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r := rr.router
+	params := ParamsFromContext(req.Context())
+	// with UseRawPath the raw values are still escaped
+	raw := r.UseRawPath && len(req.URL.RawPath) > 0
+
+	var b strings.Builder
+	for _, part := range rr.parts {
+		if part.param == "" {
+			b.WriteString(part.literal)
+			continue
+		}
+		value := params.ByName(part.param)
+		if raw {
+			value = params.RawByName(part.param)
+			if unescaped, err := url.PathUnescape(value); err == nil {
+				if part.query {
+					value = url.QueryEscape(unescaped)
+				}
+				b.WriteString(value)
+				continue
+			}
+		}
+		b.WriteString(escapeParam(value, part))
+	}
+
+	target := b.String()
+	if req.URL.RawQuery != "" {
+		if strings.IndexByte(target, '?') >= 0 {
+			target += "&" + req.URL.RawQuery
+		} else {
+			target += "?" + req.URL.RawQuery
+		}
+	}
+
+	u, err := url.Parse(target)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	location := target
+	if !rr.absolute {
+		location = r.location(req, u)
+	}
+	r.redirect(w, req, rr.code, u, location)
+}
+
+// escapeParam escapes the unescaped value of a parameter for a part of a
+// redirect target. The slashes of catch-all values separate path segments.
+func escapeParam(value string, part targetPart) string {
+	if part.query {
+		return url.QueryEscape(value)
+	}
+	if !part.catchAll {
+		return url.PathEscape(value)
+	}
+	segments := strings.Split(value, "/")
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+	return strings.Join(segments, "/")
+}
@@ -0,0 +1,169 @@
+package httprouter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRouterRedirect(t *testing.T) {
+	tests := []struct {
+		rawPath  bool
+		path     string
+		target   string
+		code     int
+		request  string
+		wantCode int
+		location string
+	}{
+		{false, "/old/:id/*rest", "/new/{id}/{rest}", 0, "/old/42/a/b", 301, "/new/42/a/b"},
+		{false, "/old/:id/*rest", "/new/{id}/{rest}", 302, "/old/42/a/b?q=1", 302, "/new/42/a/b?q=1"},
+		{false, "/old/:id", "/new/{id}", 308, "/old/a%20b", 308, "/new/a%20b"},
+		{false, "/old/:id", "/new?id={id}", 0, "/old/a&b?x=1", 301, "/new?id=a%26b&x=1"},
+		{false, "/old/*", "/new/{*}", 0, "/old/a%20b/c", 301, "/new/a%20b/c"},
+		{false, "/old/*rest", "https://example.org/{rest}", 307, "/old/x/y", 307, "https://example.org/x/y"},
+		{false, "/old/:a/:b", "/new/{b}/{a}/{a}", 0, "/old/1/2", 301, "/new/2/1/1"},
+		{true, "/old/:id", "/new/{id}", 0, "/old/a%2Fb", 301, "/new/a%2Fb"},
+		{true, "/old/*rest", "/new/{rest}", 0, "/old/a%2Fb/c", 301, "/new/a%2Fb/c"},
+		{true, "/old/:id", "/new?id={id}", 0, "/old/a%2Fb", 301, "/new?id=a%2Fb"},
+	}
+	for _, tt := range tests {
+		router := New()
+		router.UseRawPath = tt.rawPath
+		router.Redirect(http.MethodGet, tt.path, tt.target, tt.code)
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.request, nil))
+		if w.Code != tt.wantCode || w.Header().Get("Location") != tt.location {
+			t.Errorf("%s -> %s: GET %s: got %d to %q, want %d to %q",
+				tt.path, tt.target, tt.request, w.Code, w.Header().Get("Location"), tt.wantCode, tt.location)
+		}
+	}
+}
+
+func TestRouterRedirectRoute(t *testing.T) {
+	router := New()
+	router.Redirect(http.MethodGet, "/old/:id", "/new/{id}", 0, WithMetadata("owner", "legacy"))
+	router.RedirectBaseURL = "http://example.com"
+
+	route, ps, _ := router.Lookup(http.MethodGet, "/old/42")
+	if route == nil || route.Value(MetaRedirect) != "/new/{id}" || route.Value("owner") != "legacy" {
+		t.Fatalf("unexpected route %v", route)
+	}
+	if want := (Params{{Key: "id", Value: "42"}}); !reflect.DeepEqual(ps, want) {
+		t.Errorf("unexpected params %v", ps)
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/old/42", nil))
+	if location := w.Header().Get("Location"); location != "http://example.com/new/42" {
+		t.Errorf("unexpected location %q", location)
+	}
+
+	// mounted rules redirect below the prefix
+	parent := New()
+	parent.Mount("/legacy", router)
+	w = httptest.NewRecorder()
+	parent.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/legacy/old/42", nil))
+	if location := w.Header().Get("Location"); location != "http://example.com/legacy/new/42" {
+		t.Errorf("unexpected location %q below mount", location)
+	}
+}
+
+func TestRouterRedirectInvalid(t *testing.T) {
+	tests := []struct {
+		path, target string
+		code         int
+	}{
+		{"/old/:id", "/new/{name}", 0},
+		{"/old/:id", "/new/{id", 0},
+		{"/old/:id", "new/{id}", 0},
+		{"/old/:id", "/new/{id}", 200},
+		{"old", "/new", 0},
+	}
+	for _, tt := range tests {
+		recv := catchPanic(func() {
+			New().Redirect(http.MethodGet, tt.path, tt.target, tt.code)
+		})
+		if recv == nil {
+			t.Errorf("no panic for %s -> %s %d", tt.path, tt.target, tt.code)
+		}
+	}
+
+	// rules conflict with routes like any other route
+	router := New()
+	router.GET("/old/:id", func(_ http.ResponseWriter, _ *http.Request) {})
+	if recv := catchPanic(func() { router.Redirect(http.MethodGet, "/old/:id", "/new/{id}", 0) }); recv == nil {
+		t.Error("no panic for conflicting rule")
+	}
+}
+
+func TestParseRedirectRules(t *testing.T) {
+	rules, err := ParseRedirectRules([]byte(`
+# legacy articles
+GET  /old/:id/*rest -> /new/{id}/{rest} 302
+HEAD /blog/*        -> https://blog.example.com/{*}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*RedirectRule{
+		{Method: "GET", Path: "/old/:id/*rest", Target: "/new/{id}/{rest}", Code: 302, Line: 3},
+		{Method: "HEAD", Path: "/blog/*", Target: "https://blog.example.com/{*}", Line: 4},
+	}
+	if !reflect.DeepEqual(rules, want) {
+		t.Errorf("got rules %v, want %v", rules, want)
+	}
+
+	router := New()
+	if err := router.AddRedirectRules(rules); err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/old/1/x", nil))
+	if w.Code != http.StatusFound || w.Header().Get("Location") != "/new/1/x" {
+		t.Errorf("got %d to %q", w.Code, w.Header().Get("Location"))
+	}
+
+	for _, data := range []string{"GET /old /new", "GET /old => /new", "GET /old -> /new 200", "GET /old -> /new 301 x"} {
+		if _, err := ParseRedirectRules([]byte(data)); err == nil {
+			t.Errorf("no error for %q", data)
+		}
+	}
+}
+
+func TestAddRedirectRulesErrors(t *testing.T) {
+	tests := []struct {
+		rules string
+		err   string
+	}{
+		{"GET /a -> /b\nGET /old/:id -> /new/{name}", "line 2: GET /old/:id -> /new/{name}: unknown parameter {name}"},
+		{"GET /a/:x -> /b\n\nGET /a/:y -> /c", "line 3: GET /a/:y -> /c: "},
+		{"GET /taken -> /b", "line 1: GET /taken -> /b: a handle is already registered"},
+		{"GET /files/*path/x -> /b", "line 1: GET /files/*path/x -> /b: "},
+		{"GET /a -> b", "line 1: GET /a -> b: target must begin with '/'"},
+	}
+	for _, tt := range tests {
+		rules, err := ParseRedirectRules([]byte(tt.rules))
+		if err != nil {
+			t.Fatalf("%q: %v", tt.rules, err)
+		}
+		router := New()
+		router.GET("/taken", func(http.ResponseWriter, *http.Request) {})
+		err = router.AddRedirectRules(rules)
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%q: unexpected error %v", tt.rules, err)
+		}
+		if rt, _, _ := router.Lookup(http.MethodGet, "/a"); rt != nil {
+			t.Errorf("%q: rules registered despite the error", tt.rules)
+		}
+	}
+
+	// rules built in code have no line
+	err := New().AddRedirectRules([]*RedirectRule{{Method: "GET", Path: "/a", Target: "/b", Code: 200}})
+	if err == nil || err.Error() != "httprouter: GET /a -> /b 200: invalid redirect code 200 for path '/a'" {
+		t.Errorf("unexpected error %v", err)
+	}
+}
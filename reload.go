@@ -0,0 +1,223 @@
+package httprouter
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Reloader is an http.Handler serving requests with a router built from a
+// Config, which can be replaced at runtime without a restart.
+//
+// Every reload builds a fresh Router and swaps it in atomically. Requests
+// already being served finish on the router they started on.
+type Reloader struct {
+	// Resolves the names used in the configs.
+	Registry *Registry
+
+	// Returns the router the routes of a config are registered on. Use it to
+	// configure the router, e.g. to set NotFound or PanicHandler.
+	// If nil, New is used.
+	NewRouter func() *Router
+
+	// Logs the route diff of each reload. If nil, the ErrorLog of the new
+	// router is used.
+	Logf func(format string, args ...interface{})
+
+	mu       sync.Mutex // serializes reloads
+	current  atomic.Value
+	previous *reloadState
+}
+
+type reloadState struct {
+	router *Router
+	config *Config
+}
+
+// ErrNoRollback is returned by Reloader.Rollback if there is no previous
+// version to roll back to.
+var ErrNoRollback = errors.New("httprouter: no previous version to roll back to")
+
+// Router returns the router currently serving requests, or nil if no config
+// was loaded yet.
+func (rl *Reloader) Router() *Router {
+	if s := rl.state(); s != nil {
+		return s.router
+	}
+	return nil
+}
+
+func (rl *Reloader) state() *reloadState {
+	s, _ := rl.current.Load().(*reloadState)
+	return s
+}
+
+// Reload builds a new router from c and swaps it in. If c is invalid, the
+// current router keeps serving and the error is returned. The replaced router
+// is kept for Rollback.
+func (rl *Reloader) Reload(c *Config) (*RouteDiff, error) {
+	router := New()
+	if rl.NewRouter != nil {
+		router = rl.NewRouter()
+	}
+	if err := c.Apply(router, rl.Registry); err != nil {
+		return nil, err
+	}
+	router.Freeze()
+
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	old := rl.state()
+	next := &reloadState{router: router, config: c}
+	rl.current.Store(next)
+	rl.previous = old
+
+	var from *Config
+	if old != nil {
+		from = old.config
+	}
+	diff := diffConfigs(from, c)
+	rl.logf(router, "httprouter: reloaded routes: %s", diff)
+	return diff, nil
+}
+
+// ReloadFile reads a config from the given file, see LoadConfig, and reloads
+// it.
+func (rl *Reloader) ReloadFile(filename string) (*RouteDiff, error) {
+	c, err := LoadConfig(filename)
+	if err != nil {
+		return nil, err
+	}
+	return rl.Reload(c)
+}
+
+// Rollback swaps the router replaced by the last reload back in. It can be
+// undone by another Rollback.
+func (rl *Reloader) Rollback() (*RouteDiff, error) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	if rl.previous == nil {
+		return nil, ErrNoRollback
+	}
+	old, next := rl.state(), rl.previous
+	rl.current.Store(next)
+	rl.previous = old
+
+	diff := diffConfigs(old.config, next.config)
+	rl.logf(next.router, "httprouter: rolled back routes: %s", diff)
+	return diff, nil
+}
+
+func (rl *Reloader) logf(router *Router, format string, args ...interface{}) {
+	if rl.Logf != nil {
+		rl.Logf(format, args...)
+	} else {
+		router.logf(format, args...)
+	}
+}
+
+// ServeHTTP makes the reloader implement the http.Handler interface.
+// Until a config is loaded, every request is answered with 503 (Service
+// Unavailable).
+func (rl *Reloader) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	s := rl.state()
+	if s == nil {
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		return
+	}
+	s.router.ServeHTTP(w, req)
+}
+
+// RouteDiff lists the routes that differ between two configs, each as the
+// method and the path, like "GET /users/:id".
+type RouteDiff struct {
+	Added   []string
+	Removed []string
+
+	// Routes whose handler, middleware or metadata changed.
+	Changed []string
+}
+
+// Empty reports whether the configs define the same routes.
+func (d *RouteDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+func (d *RouteDiff) String() string {
+	if d.Empty() {
+		return "no changes"
+	}
+	var parts []string
+	for _, route := range d.Added {
+		parts = append(parts, "+"+route)
+	}
+	for _, route := range d.Removed {
+		parts = append(parts, "-"+route)
+	}
+	for _, route := range d.Changed {
+		parts = append(parts, "~"+route)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// routeSpec is what a route of a config resolves to, for comparison.
+type routeSpec struct {
+	handler    string
+	middleware []string
+	metadata   Metadata
+}
+
+func (c *Config) specs() map[string]routeSpec {
+	specs := make(map[string]routeSpec)
+	if c == nil {
+		return specs
+	}
+	for _, rc := range c.Routes {
+		path, err := c.fullPath(rc)
+		if err != nil {
+			continue
+		}
+		spec := routeSpec{handler: rc.Handler, metadata: make(Metadata)}
+		if g := c.Groups[rc.Group]; g != nil {
+			spec.middleware = append(spec.middleware, g.Middleware...)
+			for key, value := range g.Metadata {
+				spec.metadata[key] = value
+			}
+		}
+		spec.middleware = append(spec.middleware, rc.Middleware...)
+		for key, value := range rc.Metadata {
+			spec.metadata[key] = value
+		}
+		specs[rc.Method+" "+path] = spec
+	}
+	return specs
+}
+
+// diffConfigs returns the routes that differ between from and to. Either may
+// be nil.
+func diffConfigs(from, to *Config) *RouteDiff {
+	diff := new(RouteDiff)
+	old, next := from.specs(), to.specs()
+	for route, spec := range next {
+		if oldSpec, ok := old[route]; !ok {
+			diff.Added = append(diff.Added, route)
+		} else if !reflect.DeepEqual(spec, oldSpec) {
+			diff.Changed = append(diff.Changed, route)
+		}
+	}
+	for route := range old {
+		if _, ok := next[route]; !ok {
+			diff.Removed = append(diff.Removed, route)
+		}
+	}
+	sort.Strings(diff.Added)
+	sort.Strings(diff.Removed)
+	sort.Strings(diff.Changed)
+	return diff
+}
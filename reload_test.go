@@ -0,0 +1,133 @@
+package httprouter
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestReloader(t *testing.T) {
+	var trace []string
+	var logged []string
+	rl := &Reloader{
+		Registry: testRegistry(&trace),
+		Logf: func(format string, args ...interface{}) {
+			logged = append(logged, fmt.Sprintf(format, args...))
+		},
+	}
+
+	w := httptest.NewRecorder()
+	rl.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("unexpected status %d before the first reload", w.Code)
+	}
+	if _, err := rl.Rollback(); err != ErrNoRollback {
+		t.Errorf("unexpected rollback error %v", err)
+	}
+
+	v1, err := ParseConfig([]byte(`{"routes": [
+  {"method": "GET", "path": "/", "handler": "index"},
+  {"method": "GET", "path": "/old", "handler": "index"},
+  {"method": "GET", "path": "/user", "handler": "index"}
+]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	diff, err := rl.Reload(v1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"GET /", "GET /old", "GET /user"}; !reflect.DeepEqual(diff.Added, want) {
+		t.Errorf("unexpected added routes %v", diff.Added)
+	}
+	first := rl.Router()
+
+	v2, err := ParseConfig([]byte(`{"routes": [
+  {"method": "GET", "path": "/", "handler": "index"},
+  {"method": "GET", "path": "/new", "handler": "index"},
+  {"method": "GET", "path": "/user", "handler": "user"}
+]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	diff, err = rl.Reload(v2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := diff.String(); got != "+GET /new, -GET /old, ~GET /user" {
+		t.Errorf("unexpected diff %q", got)
+	}
+	if len(logged) != 2 || logged[1] != "httprouter: reloaded routes: +GET /new, -GET /old, ~GET /user" {
+		t.Errorf("unexpected log %q", logged)
+	}
+
+	// an invalid config keeps the current router
+	bad := &Config{Routes: []*RouteConfig{{Method: http.MethodGet, Path: "/x", Handler: "missing"}}}
+	if _, err := rl.Reload(bad); err == nil {
+		t.Error("no error for invalid config")
+	}
+
+	w = httptest.NewRecorder()
+	rl.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/old", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("removed route still served: %d", w.Code)
+	}
+
+	diff, err = rl.Rollback()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := diff.String(); got != "+GET /old, -GET /new, ~GET /user" {
+		t.Errorf("unexpected rollback diff %q", got)
+	}
+	if rl.Router() != first {
+		t.Error("rollback did not restore the previous router")
+	}
+	trace = nil
+	rl.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/user", nil))
+	if !reflect.DeepEqual(trace, []string{"index"}) {
+		t.Errorf("unexpected handler after rollback %v", trace)
+	}
+}
+
+func TestReloaderConcurrent(t *testing.T) {
+	rl := &Reloader{
+		Registry: &Registry{Handlers: map[string]http.Handler{
+			"ok": http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {}),
+		}},
+		Logf: func(string, ...interface{}) {},
+	}
+	c := &Config{Routes: []*RouteConfig{{Method: http.MethodGet, Path: "/", Handler: "ok"}}}
+	if _, err := rl.Reload(c); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				w := httptest.NewRecorder()
+				rl.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+				if w.Code != http.StatusOK {
+					t.Errorf("unexpected status %d", w.Code)
+					return
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				if _, err := rl.Reload(c); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,119 @@
+package httprouter
+
+import (
+	"context"
+	"net/http"
+	"sort"
+)
+
+// Metadata is a set of arbitrary values attached to a route at registration,
+// like the owning team, the required auth scopes or a deprecation date.
+type Metadata map[string]interface{}
+
+// Route describes a registered route.
+// Routes are shared by all requests matching them and must not be modified
+// after registration.
+type Route struct {
+	// The request method of the route.
+	Method string
+
+	// The path of the route, as registered.
+	Path string
+
+	// The handler of the route.
+	Handler http.Handler
+
+	// The metadata attached to the route, if any.
+	Metadata Metadata
+
+	// Whether the trailing slash of the route is not optional, see
+	// WithStrictSlash.
+	StrictSlash bool
+}
+
+// Value returns the metadata value stored under the given key, or nil.
+func (rt *Route) Value(key string) interface{} {
+	if rt == nil {
+		return nil
+	}
+	return rt.Metadata[key]
+}
+
+// RouteOption configures a route at registration.
+type RouteOption func(*Route)
+
+// WithMetadata attaches the given metadata value to a route.
+func WithMetadata(key string, value interface{}) RouteOption {
+	return func(rt *Route) {
+		if rt.Metadata == nil {
+			rt.Metadata = make(Metadata)
+		}
+		rt.Metadata[key] = value
+	}
+}
+
+// WithStrictSlash excludes a route from Router.IgnoreTrailingSlash: it only
+// matches its path with or without the trailing slash, as registered.
+func WithStrictSlash() RouteOption {
+	return func(rt *Route) {
+		rt.StrictSlash = true
+	}
+}
+
+type routeKey struct{}
+
+// RouteKey is the request context key under which the matched route is
+// stored. To keep requests to plain routes free of allocations, the route is
+// only stored if it has metadata or if the router has Hooks or Metrics.
+var RouteKey = routeKey{}
+
+// RouteFromContext pulls the matched route from a request context,
+// or returns nil if none is present.
+func RouteFromContext(ctx context.Context) *Route {
+	rt, _ := ctx.Value(RouteKey).(*Route)
+	return rt
+}
+
+type rewriteKey struct{}
+
+// RewriteKey is the request context key under which the original path of a
+// request rewritten by the router is stored, see Router.RewritePaths.
+var RewriteKey = rewriteKey{}
+
+// RewriteFromContext pulls the original path of a rewritten request from a
+// request context, or returns "" if the request was not rewritten.
+func RewriteFromContext(ctx context.Context) string {
+	path, _ := ctx.Value(RewriteKey).(string)
+	return path
+}
+
+// Lookup allows the manual lookup of a method + path combo.
+// This is e.g. useful to build a framework around this router.
+// If the path was found, it returns the route and the URL parameters.
+// Otherwise the third return value indicates whether a redirection to
+// the same path with an extra / without the trailing slash should be performed.
+func (r *Router) Lookup(method, path string) (*Route, Params, bool) {
+	n, params, tsr := r.lookup(method, path)
+	if n == nil {
+		if n, params := r.lookupMount(path); n != nil {
+			return n.route, params, false
+		}
+		return nil, nil, tsr
+	}
+	return n.route, params, false
+}
+
+// Routes returns all registered routes, sorted by path and method.
+func (r *Router) Routes() []*Route {
+	var routes []*Route
+	for _, root := range r.trees {
+		routes = root.collectRoutes(routes)
+	}
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Path != routes[j].Path {
+			return routes[i].Path < routes[j].Path
+		}
+		return routes[i].Method < routes[j].Method
+	})
+	return routes
+}
@@ -0,0 +1,107 @@
+package httprouter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestRouteMetadata(t *testing.T) {
+	router := New()
+
+	var route *Route
+	router.GET("/user/:name", func(_ http.ResponseWriter, r *http.Request) {
+		route = RouteFromContext(r.Context())
+	}, WithMetadata("owner", "accounts"), WithMetadata("scopes", []string{"user:read"}))
+
+	r, _ := http.NewRequest(http.MethodGet, "/user/gopher", nil)
+	router.ServeHTTP(httptest.NewRecorder(), r)
+
+	if route == nil {
+		t.Fatal("no route in request context")
+	}
+	if route.Method != http.MethodGet || route.Path != "/user/:name" {
+		t.Errorf("unexpected route %s %s", route.Method, route.Path)
+	}
+	if route.Value("owner") != "accounts" {
+		t.Errorf("unexpected owner %v", route.Value("owner"))
+	}
+	if scopes := route.Value("scopes"); !reflect.DeepEqual(scopes, []string{"user:read"}) {
+		t.Errorf("unexpected scopes %v", scopes)
+	}
+	if route.Value("missing") != nil {
+		t.Error("unexpected value for missing key")
+	}
+
+	var nilRoute *Route
+	if nilRoute.Value("owner") != nil {
+		t.Error("unexpected value for nil route")
+	}
+}
+
+func TestRouterLookup(t *testing.T) {
+	router := New()
+	router.GET("/user/:name", func(_ http.ResponseWriter, _ *http.Request) {}, WithMetadata("owner", "accounts"))
+	router.GET("/dir/", func(_ http.ResponseWriter, _ *http.Request) {})
+
+	route, ps, tsr := router.Lookup(http.MethodGet, "/user/gopher")
+	if route == nil || route.Path != "/user/:name" || tsr {
+		t.Fatalf("unexpected lookup result %v, %t", route, tsr)
+	}
+	if route.Value("owner") != "accounts" {
+		t.Errorf("unexpected owner %v", route.Value("owner"))
+	}
+	if want := (Params{Param{Key: "name", Value: "gopher"}}); !reflect.DeepEqual(ps, want) {
+		t.Errorf("unexpected params %v", ps)
+	}
+
+	route, _, tsr = router.Lookup(http.MethodGet, "/dir")
+	if route != nil || !tsr {
+		t.Errorf("expected trailing slash recommendation, got %v, %t", route, tsr)
+	}
+
+	route, _, tsr = router.Lookup(http.MethodPost, "/user/gopher")
+	if route != nil || tsr {
+		t.Errorf("unexpected lookup result %v, %t", route, tsr)
+	}
+}
+
+func TestRouterRoutes(t *testing.T) {
+	handlerFunc := func(_ http.ResponseWriter, _ *http.Request) {}
+
+	router := New()
+	router.POST("/users", handlerFunc)
+	router.GET("/users", handlerFunc)
+	router.GET("/users/:name", handlerFunc)
+	router.GET("/src/*filepath", handlerFunc)
+	router.GET("/", handlerFunc)
+
+	api := router.Group("/api")
+	api.Metadata = Metadata{"owner": "platform", "rate": "default"}
+	v1 := api.Group("/v1")
+	v1.Metadata = Metadata{"version": 1}
+	v1.GET("/items", handlerFunc, WithMetadata("rate", "search"))
+
+	var got []string
+	for _, route := range router.Routes() {
+		got = append(got, route.Method+" "+route.Path)
+	}
+	want := []string{
+		"GET /",
+		"GET /api/v1/items",
+		"GET /src/*filepath",
+		"GET /users",
+		"POST /users",
+		"GET /users/:name",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected routes %v, want %v", got, want)
+	}
+
+	route, _, _ := router.Lookup(http.MethodGet, "/api/v1/items")
+	wantMetadata := Metadata{"owner": "platform", "rate": "search", "version": 1}
+	if !reflect.DeepEqual(route.Metadata, wantMetadata) {
+		t.Errorf("unexpected group metadata %v, want %v", route.Metadata, wantMetadata)
+	}
+}
@@ -8,14 +8,34 @@ package httprouter
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"html"
+	"log"
 	"net/http"
+	"net/url"
+	"runtime/debug"
 	"strings"
+	"time"
 )
 
 // Param is a single URL parameter, consisting of a key and a value.
 type Param struct {
 	Key   string
 	Value string
+
+	// The value as matched against the path, if Value was changed from it
+	// by Router.UnescapePathValues or Router.SafePathValues. Otherwise it is
+	// empty, see Params.RawByName.
+	Raw string
+}
+
+// raw returns the value of p as matched against the path.
+func (p Param) raw() string {
+	if p.Raw != "" {
+		return p.Raw
+	}
+	return p.Value
 }
 
 // Params is a Param-slice, as returned by the router.
@@ -34,6 +54,20 @@ func (ps Params) ByName(name string) string {
 	return ""
 }
 
+// RawByName returns the value of the first Param which key matches the given
+// name as matched against the path, before it was unescaped (see
+// Router.UnescapePathValues) or cleaned (see Router.SafePathValues). This way
+// an escaped slash in a value can be told apart from a path separator.
+// If no matching Param is found, an empty string is returned.
+func (ps Params) RawByName(name string) string {
+	for _, p := range ps {
+		if p.Key == name {
+			return p.raw()
+		}
+	}
+	return ""
+}
+
 var catchAllParam = "$catchAllParam"
 
 // CatchAll retrieves the remaining path matched by the catch all, if
@@ -42,6 +76,11 @@ func (ps Params) CatchAll() string {
 	return ps.ByName(catchAllParam)
 }
 
+// joinParams returns the params of a followed by those of b.
+func joinParams(a, b Params) Params {
+	return append(a[:len(a):len(a)], b...)
+}
+
 type paramsKey struct{}
 
 // ParamsKey is the request context key under which URL params are stored.
@@ -59,6 +98,12 @@ func ParamsFromContext(ctx context.Context) Params {
 type Router struct {
 	trees map[string]*node
 
+	// Per-method index of the routes without any wildcard, built by Freeze
+	static map[string]map[string]*node
+
+	// Handlers mounted with Mount, for any method
+	mounts *node
+
 	// If enabled, the router checks if another method is allowed for the
 	// current route, if the current request can not be routed.
 	// If this is the case, the request is answered with 'Method Not Allowed'
@@ -80,6 +125,15 @@ type Router struct {
 	// Cached value of global (*) allowed methods
 	globalAllowed string
 
+	// An optional CORS policy applied to all requests.
+	// Groups may override it for the requests below their prefix.
+	// Preflight requests are answered by the automatic OPTIONS replies, so
+	// HandleOPTIONS should be enabled as well.
+	CORS *CORS
+
+	// Groups created with Group, consulted for their settings
+	groups *groupIndex
+
 	// Configurable http.Handler which is called when no matching route is
 	// found. If it is not set, http.NotFound is used.
 	NotFound http.Handler
@@ -91,6 +145,14 @@ type Router struct {
 	// is called.
 	MethodNotAllowed http.Handler
 
+	// An optional function rendering the responses generated by the router
+	// itself: not found and method not allowed replies, redirects and
+	// recovered panics. It is only used where no more specific handler, like
+	// NotFound, is configured. RenderProblem can be used to reply with
+	// RFC 7807 problem details.
+	// If it is set, panics are recovered like with RecoverPanics.
+	ErrorRenderer func(http.ResponseWriter, *http.Request, *Problem)
+
 	// Function to handle panics recovered from http handlers.
 	// It should be used to generate a error page and return the http error code
 	// 500 (Internal Server Error).
@@ -98,9 +160,61 @@ type Router struct {
 	// unrecovered panics.
 	PanicHandler func(http.ResponseWriter, *http.Request, interface{})
 
+	// Function to handle panics recovered from http handlers, receiving a
+	// report with the stack trace and the matched route of the request.
+	// It takes priority over PanicHandler.
+	PanicReportHandler func(http.ResponseWriter, *http.Request, *PanicReport)
+
+	// If enabled, panics are recovered even if neither PanicHandler nor
+	// PanicReportHandler is set. They are then handled by
+	// DefaultPanicReportHandler.
+	// The http.ErrAbortHandler panic is never recovered, so that net/http can
+	// abort the response.
+	RecoverPanics bool
+
+	// Specifies an optional logger for panics recovered by
+	// DefaultPanicReportHandler. If nil, logging is done via the log
+	// package's standard logger.
+	ErrorLog *log.Logger
+
+	// Optional metrics recording the count and latency of every request
+	// served by the router, including not found and method not allowed
+	// replies, redirects and panics.
+	Metrics *Metrics
+
+	// Optional hooks observing the decisions made while dispatching requests,
+	// like matching a route, redirecting or replying 404. See EventKind.
+	Hooks Hooks
+
+	// If positive, requests with longer paths are rejected with 414 (URI Too
+	// Long) before looking up their route. With UseRawPath the length of the
+	// escaped path counts.
+	MaxPathLength int
+
+	// If positive, requests with paths of more segments are rejected with 400
+	// (Bad Request) before looking up their route.
+	MaxSegments int
+
+	// An optional policy for the escapes in request paths, enforced before
+	// looking up routes. Requests violating it are rejected with 400 (Bad
+	// Request).
+	PathEncoding *PathEncoding
+
 	// If enabled, the router prefers URL.RawPath for route matching instead of the unescaped URL.Path.
 	UseRawPath bool
 
+	// If enabled together with UseRawPath, the values of Params matched
+	// against URL.RawPath are unescaped. The escaped values are still
+	// available through Params.RawByName.
+	UnescapePathValues bool
+
+	// If enabled, requests are rejected with 400 (Bad Request) if the values
+	// of their Params contain control characters or ".." segments leading
+	// above the path they are found at, like "../../etc/passwd". The values of
+	// catch-alls are cleaned like by CleanPath. The values as matched are
+	// still available through Params.RawByName.
+	SafePathValues bool
+
 	// If enabled, the router tries to fix the current request path, if no
 	// handle is registered for it.
 	// First superfluous path elements like ../ or // are removed.
@@ -119,8 +233,32 @@ type Router struct {
 	// and 308 for all other request methods.
 	RedirectTrailingSlash bool
 
-	// An optional handler that is called if any redirect is made by the router. If not provided,
-	// a regular http.Redirect call is made.
+	// If enabled, the trailing slash of paths is optional: if the current
+	// route can't be matched but a handler for the path with (without) the
+	// trailing slash exists, it is called without any redirection.
+	// Routes registered with WithStrictSlash are excluded, so that requests
+	// for them are still redirected if RedirectTrailingSlash is enabled.
+	// Routes registered for both paths each keep serving their own path.
+	IgnoreTrailingSlash bool
+
+	// If enabled, requests that would be redirected because of
+	// RedirectTrailingSlash or RedirectFixedPath are served right away by the
+	// handler of the corrected path instead, as if it had been requested.
+	// URL.Path is rewritten and the original path is stored in the request
+	// context, see RewriteFromContext. This spares clients which can't follow
+	// redirects the round trip.
+	RewritePaths bool
+
+	// If set, the Location of redirects made by the router is an absolute URL
+	// below this base URL, like "https://example.com". Otherwise it is an
+	// absolute path. The host and the scheme of the request are never used:
+	// the Host header is chosen by the client, and behind a TLS terminating
+	// proxy the scheme is not the one of the client.
+	RedirectBaseURL string
+
+	// An optional handler that is called if any redirect is made by the router.
+	// The URL of the request it receives is the redirect target.
+	// If not provided, a regular redirect reply is written.
 	RedirectHandler func(http.ResponseWriter, *http.Request, int)
 }
 
@@ -139,47 +277,47 @@ func New() *Router {
 }
 
 // GET is a shortcut for router.HandlerFunc(http.MethodGet, path, handle)
-func (r *Router) GET(path string, handle func(http.ResponseWriter, *http.Request)) {
-	r.HandlerFunc(http.MethodGet, path, handle)
+func (r *Router) GET(path string, handle func(http.ResponseWriter, *http.Request), opts ...RouteOption) {
+	r.HandlerFunc(http.MethodGet, path, handle, opts...)
 }
 
 // HEAD is a shortcut for router.HandlerFunc(http.MethodHead, path, handle)
-func (r *Router) HEAD(path string, handle func(http.ResponseWriter, *http.Request)) {
-	r.HandlerFunc(http.MethodHead, path, handle)
+func (r *Router) HEAD(path string, handle func(http.ResponseWriter, *http.Request), opts ...RouteOption) {
+	r.HandlerFunc(http.MethodHead, path, handle, opts...)
 }
 
 // OPTIONS is a shortcut for router.HandlerFunc(http.MethodOptions, path, handle)
-func (r *Router) OPTIONS(path string, handle func(http.ResponseWriter, *http.Request)) {
-	r.HandlerFunc(http.MethodOptions, path, handle)
+func (r *Router) OPTIONS(path string, handle func(http.ResponseWriter, *http.Request), opts ...RouteOption) {
+	r.HandlerFunc(http.MethodOptions, path, handle, opts...)
 }
 
 // POST is a shortcut for router.HandlerFunc(http.MethodPost, path, handle)
-func (r *Router) POST(path string, handle func(http.ResponseWriter, *http.Request)) {
-	r.HandlerFunc(http.MethodPost, path, handle)
+func (r *Router) POST(path string, handle func(http.ResponseWriter, *http.Request), opts ...RouteOption) {
+	r.HandlerFunc(http.MethodPost, path, handle, opts...)
 }
 
 // PUT is a shortcut for router.HandlerFunc(http.MethodPut, path, handle)
-func (r *Router) PUT(path string, handle func(http.ResponseWriter, *http.Request)) {
-	r.HandlerFunc(http.MethodPut, path, handle)
+func (r *Router) PUT(path string, handle func(http.ResponseWriter, *http.Request), opts ...RouteOption) {
+	r.HandlerFunc(http.MethodPut, path, handle, opts...)
 }
 
 // PATCH is a shortcut for router.HandlerFunc(http.MethodPatch, path, handle)
-func (r *Router) PATCH(path string, handle func(http.ResponseWriter, *http.Request)) {
-	r.HandlerFunc(http.MethodPatch, path, handle)
+func (r *Router) PATCH(path string, handle func(http.ResponseWriter, *http.Request), opts ...RouteOption) {
+	r.HandlerFunc(http.MethodPatch, path, handle, opts...)
 }
 
 // DELETE is a shortcut for router.HandlerFunc(http.MethodDelete, path, handle)
-func (r *Router) DELETE(path string, handle func(http.ResponseWriter, *http.Request)) {
-	r.HandlerFunc(http.MethodDelete, path, handle)
+func (r *Router) DELETE(path string, handle func(http.ResponseWriter, *http.Request), opts ...RouteOption) {
+	r.HandlerFunc(http.MethodDelete, path, handle, opts...)
 }
 
 // HandlerFunc is an adapter which allows the usage of an http.HandlerFunc as a
 // request handle.
-func (r *Router) HandlerFunc(method, path string, handle func(http.ResponseWriter, *http.Request)) {
+func (r *Router) HandlerFunc(method, path string, handle func(http.ResponseWriter, *http.Request), opts ...RouteOption) {
 	if handle == nil {
 		panic("handle must not be nil")
 	}
-	r.Handler(method, path, http.HandlerFunc(handle))
+	r.Handler(method, path, http.HandlerFunc(handle), opts...)
 }
 
 // Handler registers a new request handle with the given path and method.
@@ -190,7 +328,9 @@ func (r *Router) HandlerFunc(method, path string, handle func(http.ResponseWrite
 // This function is intended for bulk loading and to allow the usage of less
 // frequently used, non-standardized or custom methods (e.g. for internal
 // communication with a proxy).
-func (r *Router) Handler(method, path string, handle http.Handler) {
+//
+// The given options configure the route, e.g. to attach metadata to it.
+func (r *Router) Handler(method, path string, handle http.Handler, opts ...RouteOption) {
 	if method == "" {
 		panic("method must not be empty")
 	}
@@ -213,7 +353,98 @@ func (r *Router) Handler(method, path string, handle http.Handler) {
 		r.globalAllowed = r.allowed("*", "")
 	}
 
-	root.addRoute(path, handle)
+	route := &Route{
+		Method:  method,
+		Path:    path,
+		Handler: handle,
+	}
+	for _, opt := range opts {
+		opt(route)
+	}
+	if err := parseRateLimit(route); err != nil {
+		panic(err.Error() + " in path '" + path + "'")
+	}
+	root.insertRoute(route)
+
+	// the static index would be stale now
+	r.static = nil
+}
+
+// CheckRoute reports whether a route with the given method and path could be
+// registered, without registering it. It returns the error Handler would
+// panic with otherwise, e.g. for a malformed path or a conflict with an
+// already registered route.
+func (r *Router) CheckRoute(method, path string) (err error) {
+	if method == "" {
+		return errors.New("method must not be empty")
+	}
+	if len(path) < 1 || path[0] != '/' {
+		return errors.New("path must begin with '/' in path '" + path + "'")
+	}
+
+	defer func() {
+		if rcv := recover(); rcv != nil {
+			err = fmt.Errorf("%v", rcv)
+		}
+	}()
+	normalized, _ := normalizePath(path)
+
+	if root := r.trees[method]; root != nil {
+		if n := root.findRoute(normalized); n != nil && n.handle != nil {
+			return errors.New("a handle is already registered for path '" + path + "', existing path '" + n.route.Path + "'")
+		}
+	}
+	return nil
+}
+
+// tryHandler registers a route like Handler, but returns an error instead
+// of panicking.
+func (r *Router) tryHandler(method, path string, handle http.Handler, opts ...RouteOption) error {
+	if err := r.CheckRoute(method, path); err != nil {
+		return err
+	}
+	r.Handler(method, path, handle, opts...)
+	return nil
+}
+
+// Freeze compiles the registered routes for faster lookups.
+// Routes without any wildcard are indexed in a per-method hash map, which is
+// consulted before the tree is walked. Routes with parameters keep using the
+// tree.
+// Freeze must be called after all routes are registered and before the router
+// starts serving requests. Registering another route afterwards discards the
+// index until Freeze is called again.
+func (r *Router) Freeze() {
+	static := make(map[string]map[string]*node, len(r.trees))
+	for method, root := range r.trees {
+		routes := make(map[string]*node)
+		root.collectStatic("", routes)
+		if len(routes) > 0 {
+			static[method] = routes
+		}
+	}
+	r.static = static
+}
+
+// appendMethods adds the methods of an Allow header value to allowed, except
+// OPTIONS and those already in allowed.
+func appendMethods(allowed []string, allow string) []string {
+	if allow == "" {
+		return allowed
+	}
+outer:
+	for _, method := range strings.Split(allow, ", ") {
+		if method == http.MethodOptions {
+			continue
+		}
+		for _, m := range allowed {
+			if m == method {
+				continue outer
+			}
+		}
+		allowed = append(allowed, method)
+	}
+	return allowed
 }
 
 func (r *Router) allowed(path, reqMethod string) (allow string) {
@@ -229,8 +460,19 @@ func (r *Router) allowed(path, reqMethod string) (allow string) {
 				// Add request method to list of allowed methods
 				allowed = append(allowed, method)
 			}
-		} else {
+		} else if r.mounts == nil {
 			return r.globalAllowed
+		} else {
+			for method := range r.trees {
+				if method != http.MethodOptions {
+					allowed = append(allowed, method)
+				}
+			}
+			for _, rt := range r.mounts.collectRoutes(nil) {
+				if child := rt.Handler.(*mount).child; child != nil {
+					allowed = appendMethods(allowed, child.allowed(path, reqMethod))
+				}
+			}
 		}
 	} else { // specific path
 		for method := range r.trees {
@@ -239,12 +481,18 @@ func (r *Router) allowed(path, reqMethod string) (allow string) {
 				continue
 			}
 
-			foundNode, _ := r.trees[method].search(path)
-			if foundNode != nil && foundNode.handle != nil {
+			if foundNode, _, _ := r.lookup(method, path); foundNode != nil {
 				// Add request method to list of allowed methods
 				allowed = append(allowed, method)
 			}
 		}
+
+		// consult the sub-router mounted at the path, if any
+		if n, params := r.lookupMount(path); n != nil {
+			if child := n.handle.(*mount).child; child != nil {
+				allowed = appendMethods(allowed, child.allowed(subPath(params), reqMethod))
+			}
+		}
 	}
 
 	if len(allowed) > 0 {
@@ -266,21 +514,136 @@ func (r *Router) allowed(path, reqMethod string) (allow string) {
 	return
 }
 
-func (r *Router) recv(w http.ResponseWriter, req *http.Request) {
+// PanicReport describes a panic recovered while serving a request.
+type PanicReport struct {
+	// The value passed to panic.
+	Value interface{}
+
+	// The stack trace of the panicking goroutine.
+	Stack []byte
+
+	// The route matched by the request, if any.
+	Route string
+
+	// The URL parameters of the matched route.
+	Params Params
+
+	// Whether the response was already started when the panic occurred.
+	// If so, its status code can't be changed anymore.
+	HeadersWritten bool
+}
+
+// recovers reports whether panics should be recovered.
+func (r *Router) recovers() bool {
+	return r.PanicHandler != nil || r.PanicReportHandler != nil ||
+		r.RecoverPanics || r.ErrorRenderer != nil
+}
+
+// dispatch is filled in while dispatching a request, for the deferred panic
+// recovery, metrics and hooks.
+type dispatch struct {
+	report  PanicReport
+	route   *Route
+	outcome string
+}
+
+func (r *Router) recv(w *responseWriter, req *http.Request, d *dispatch) {
 	if rcv := recover(); rcv != nil {
-		r.PanicHandler(w, req, rcv)
+		if rcv == http.ErrAbortHandler {
+			// net/http relies on this panic to abort the response
+			panic(rcv)
+		}
+
+		// a copy, so that d doesn't escape to the heap for every request
+		report := d.report
+		report.Value = rcv
+		d.outcome = OutcomePanic
+		r.emit(Event{
+			Kind:    EventPanic,
+			Request: req,
+			Route:   d.route,
+			Params:  report.Params,
+			Status:  http.StatusInternalServerError,
+			Panic:   rcv,
+		})
+
+		if r.PanicReportHandler == nil && r.PanicHandler != nil {
+			r.PanicHandler(w, req, rcv)
+			return
+		}
+
+		report.Stack = debug.Stack()
+		report.HeadersWritten = w.started()
+		if r.PanicReportHandler != nil {
+			r.PanicReportHandler(w, req, &report)
+		} else {
+			r.DefaultPanicReportHandler(w, req, &report)
+		}
+	}
+}
+
+// DefaultPanicReportHandler logs the report of a recovered panic to ErrorLog.
+// If the response was not started yet, it replies with 500 (Internal Server
+// Error), rendered by ErrorRenderer if set.
+func (r *Router) DefaultPanicReportHandler(w http.ResponseWriter, req *http.Request, report *PanicReport) {
+	r.logf("httprouter: panic serving %s %s (route %q): %v\n%s",
+		req.Method, req.URL.Path, report.Route, report.Value, report.Stack)
+
+	if report.HeadersWritten {
+		return
+	}
+	if r.ErrorRenderer != nil {
+		p := newProblem(req, http.StatusInternalServerError)
+		p.Route = report.Route
+		r.ErrorRenderer(w, req, p)
+	} else {
+		http.Error(w,
+			http.StatusText(http.StatusInternalServerError),
+			http.StatusInternalServerError,
+		)
+	}
+}
+
+func (r *Router) logf(format string, args ...interface{}) {
+	if r.ErrorLog != nil {
+		r.ErrorLog.Printf(format, args...)
+	} else {
+		log.Printf(format, args...)
 	}
 }
 
-func (r *Router) lookup(method, path string) (http.Handler, Params) {
+// lookup finds the node with the handle registered for the given method and
+// path. If there is none, tsr reports whether a handle exists for the path with an
+// extra or without the trailing slash.
+func (r *Router) lookup(method, path string) (n *node, params Params, tsr bool) {
+	n, params, tsr = r.match(method, path)
+	if tsr && r.IgnoreTrailingSlash {
+		if n, params, _ := r.match(method, fixSlash(path)); n != nil && !n.route.StrictSlash {
+			return n, params, false
+		}
+	}
+	return n, params, tsr
+}
+
+// match looks up the route of the given method matching path exactly.
+func (r *Router) match(method, path string) (n *node, params Params, tsr bool) {
 	if path == "" {
-		return nil, nil
+		return nil, nil, false
 	}
 
 	if root := r.trees[method]; root != nil {
-		nodeFound, paramValues := root.search(path)
+		// Static routes always win over wildcards in the tree, so a hit in the
+		// index is exactly what the tree walk would return. Escaped paths are
+		// left to the tree, which matches them against their unescaped form.
+		if routes := r.static[method]; routes != nil && strings.IndexByte(path, '%') < 0 {
+			if n := routes[path]; n != nil {
+				return n, nil, false
+			}
+		}
+
+		nodeFound, paramValues, tsr := root.search(path)
 		if nodeFound == nil || nodeFound.handle == nil {
-			return nil, nil
+			return nil, nil, tsr
 		}
 
 		if len(paramValues) > 0 {
@@ -292,46 +655,286 @@ func (r *Router) lookup(method, path string) (http.Handler, Params) {
 					params[i] = Param{Key: name, Value: paramValues[i]}
 				}
 			}
-			return nodeFound.handle, params
+			return nodeFound, params, false
+		}
+
+		return nodeFound, nil, false
+	}
+	return nil, nil, false
+}
+
+// matchPath returns the path of u the router matches routes against.
+func (r *Router) matchPath(u *url.URL) string {
+	if r.UseRawPath && len(u.RawPath) > 0 {
+		return u.RawPath
+	}
+	return u.Path
+}
+
+// fixedURL returns a copy of u with the given fix applied to its path.
+// The fix is applied to both the escaped and the unescaped form of the path,
+// so that URL.Path and URL.RawPath stay consistent. The query is kept
+// verbatim.
+func (r *Router) fixedURL(u *url.URL, fix func(string) string) *url.URL {
+	fixed := *u
+	if r.UseRawPath && len(u.RawPath) > 0 {
+		fixed.RawPath = fix(u.RawPath)
+		fixed.Path = fixed.RawPath
+		if unescaped, err := url.PathUnescape(fixed.RawPath); err == nil {
+			fixed.Path = unescaped
+		}
+	} else {
+		fixed.Path = fix(u.Path)
+		fixed.RawPath = ""
+		if len(u.RawPath) > 0 {
+			// keep the escaping of the request if it survives the fix, like
+			// an escaped slash
+			escaped := fix(u.EscapedPath())
+			if unescaped, err := url.PathUnescape(escaped); err == nil && unescaped == fixed.Path {
+				fixed.RawPath = escaped
+			}
 		}
+	}
+	if len(fixed.RawPath) > 0 && (&url.URL{Path: fixed.Path}).EscapedPath() == fixed.RawPath {
+		// the default encoding is the same
+		fixed.RawPath = ""
+	}
+	return &fixed
+}
+
+// location returns the Location of a redirect of req to u.
+func (r *Router) location(req *http.Request, u *url.URL) string {
+	// the client knows the path including the prefix of a sub-router
+	path := mountRawPrefix(req.Context()) + u.EscapedPath()
+	if strings.HasPrefix(path, "//") {
+		// would be taken for a host by the client
+		path = "/" + strings.TrimLeft(path, "/")
+	}
 
-		return nodeFound.handle, nil
+	location := path
+	if u.ForceQuery || u.RawQuery != "" {
+		location += "?" + u.RawQuery
+	}
+	if r.RedirectBaseURL != "" {
+		location = strings.TrimSuffix(r.RedirectBaseURL, "/") + location
 	}
-	return nil, nil
+	return location
 }
 
-func (r *Router) redirect(w http.ResponseWriter, req *http.Request, code int) {
+// redirect redirects req to u.
+func (r *Router) redirect(w http.ResponseWriter, req *http.Request, code int, u *url.URL, location string) {
 	if r.RedirectHandler != nil {
-		r.RedirectHandler(w, req, code)
+		target := req.WithContext(req.Context())
+		target.URL = u
+		r.RedirectHandler(w, target, code)
+	} else if r.ErrorRenderer != nil {
+		p := newProblem(req, code)
+		p.Location = location
+		p.Route = r.routeOf(req.Method, r.matchPath(u))
+		w.Header().Set("Location", p.Location)
+		r.ErrorRenderer(w, req, p)
 	} else {
-		http.Redirect(w, req, req.URL.String(), code)
+		// like http.Redirect, which would clean the path of the location
+		h := w.Header()
+		h.Set("Location", location)
+		if req.Method == http.MethodGet || req.Method == http.MethodHead {
+			h.Set("Content-Type", "text/html; charset=utf-8")
+		}
+		w.WriteHeader(code)
+		if req.Method == http.MethodGet {
+			fmt.Fprintf(w, "<a href=\"%s\">%s</a>.\n", html.EscapeString(location), http.StatusText(code))
+		}
+	}
+}
+
+// finish records a served request in the metrics of the router. A panic not
+// recovered by the router is passed to the hooks and the metrics, then passed
+// on.
+func (r *Router) finish(w *responseWriter, req *http.Request, d *dispatch, start time.Time) {
+	rcv := recover()
+	if rcv != nil {
+		d.outcome = OutcomePanic
+		r.emit(Event{
+			Kind:    EventPanic,
+			Request: req,
+			Route:   d.route,
+			Params:  d.report.Params,
+			Status:  http.StatusInternalServerError,
+			Panic:   rcv,
+		})
+	}
+	if r.Metrics == nil {
+		if rcv != nil {
+			panic(rcv)
+		}
+		return
+	}
+
+	status := w.status
+	if status == 0 {
+		status = http.StatusOK
+		if rcv != nil {
+			// net/http replies 500 if possible
+			status = http.StatusInternalServerError
+		}
+	}
+	r.Metrics.observe(req.Method, d.report.Route, d.outcome, status, time.Since(start))
+
+	if rcv != nil {
+		panic(rcv)
+	}
+}
+
+// serveRoute calls the handle of the route matched by req.
+func (r *Router) serveRoute(w http.ResponseWriter, req *http.Request, n *node, params Params, d *dispatch) {
+	if values := r.unescapeParams(req.URL, params); values != nil {
+		params = values
+	}
+	d.route = n.route
+	d.report.Route = n.route.Path
+	if r.SafePathValues && len(params) > 0 {
+		_, mounted := n.handle.(*mount)
+		// the values are still escaped unless unescaped above
+		escaped := r.UseRawPath && len(req.URL.RawPath) > 0 && !r.UnescapePathValues
+		safe, err := sanitizeParams(params, n.route.Path, escaped, mounted)
+		if err != nil {
+			r.reject(w, req, http.StatusBadRequest, err, d)
+			return
+		}
+		if safe != nil {
+			params = safe
+		}
+	}
+	d.report.Params = params
+
+	ctx := req.Context()
+	if _, mounted := ctx.Value(MountPrefixKey).(string); mounted {
+		// append to the params of the prefix of a sub-router
+		if parent := ParamsFromContext(ctx); len(parent) > 0 {
+			params = joinParams(parent, params)
+		}
+	}
+	if n.route.Metadata != nil || r.Hooks != nil || r.Metrics != nil {
+		// only stored when it may be of use, it costs allocations
+		ctx = context.WithValue(ctx, RouteKey, n.route)
+	}
+	if len(params) > 0 {
+		ctx = context.WithValue(ctx, ParamsKey, params)
+	}
+	if ctx != req.Context() {
+		req = req.WithContext(ctx)
 	}
+	d.outcome = OutcomeMatched
+	r.emit(Event{Kind: EventMatched, Request: req, Route: n.route, Params: params})
+	n.handle.ServeHTTP(w, req)
+}
+
+// unescapeParams returns the params matched against the escaped path of u
+// with their values unescaped, if UnescapePathValues is set. It returns nil if
+// no value was changed.
+func (r *Router) unescapeParams(u *url.URL, params Params) Params {
+	if !r.UnescapePathValues || !r.UseRawPath || len(u.RawPath) == 0 {
+		return nil
+	}
+	var values Params
+	for i, p := range params {
+		unescaped, err := url.PathUnescape(p.Value)
+		if err != nil || unescaped == p.Value {
+			continue
+		}
+		if values == nil {
+			values = make(Params, len(params))
+			copy(values, params)
+		}
+		values[i].Value, values[i].Raw = unescaped, p.Value
+	}
+	return values
+}
+
+// rewrite serves req with the route registered for the corrected URL u, as if
+// it had been requested. It reports whether there is such a route.
+func (r *Router) rewrite(w http.ResponseWriter, req *http.Request, u *url.URL, d *dispatch) bool {
+	n, params, _ := r.lookup(req.Method, r.matchPath(u))
+	if n == nil {
+		return false
+	}
+
+	location := r.location(req, u)
+	req = req.WithContext(context.WithValue(req.Context(), RewriteKey, req.URL.Path))
+	req.URL = u
+
+	r.emit(Event{Kind: EventRewrite, Request: req, Location: location})
+	r.serveRoute(w, req, n, params, d)
+	return true
 }
 
 // ServeHTTP makes the router implement the http.Handler interface.
 func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
-	if r.PanicHandler != nil {
-		defer r.recv(w, req)
+	d := dispatch{outcome: OutcomeNotFound}
+	var rw *responseWriter // only needed for the status of a response
+	if r.recovers() || r.Metrics != nil {
+		rw, w = wrapWriter(w)
+	}
+	if r.Metrics != nil || r.Hooks != nil {
+		defer r.finish(rw, req, &d, time.Now())
+	}
+	if r.recovers() {
+		defer r.recv(rw, req, &d)
 	}
 
-	path := req.URL.Path
-	if r.UseRawPath && len(req.URL.RawPath) > 0 {
-		path = req.URL.RawPath
+	if code, err := r.checkLimits(r.matchPath(req.URL)); err != nil {
+		r.reject(w, req, code, err, &d)
+		return
+	}
+	if r.PathEncoding != nil {
+		u, err := r.PathEncoding.apply(req.URL)
+		if err != nil {
+			r.reject(w, req, http.StatusBadRequest, err, &d)
+			return
+		}
+		if u != req.URL {
+			req = req.WithContext(req.Context())
+			req.URL = u
+		}
+	}
+
+	path := r.matchPath(req.URL)
+
+	cors := r.corsPolicy(path)
+	preflight := cors != nil && isPreflight(req)
+	if cors != nil && !preflight {
+		cors.handleActual(w, req)
 	}
 
-	if handle, params := r.lookup(req.Method, path); handle != nil {
-		if len(params) > 0 {
-			req = req.WithContext(
-				context.WithValue(req.Context(), ParamsKey, params),
-			)
+	n, params, tsr := r.lookup(req.Method, path)
+	if n == nil {
+		n, params = r.lookupMount(path)
+		if n != nil && !n.handle.(*mount).serves(req.Method, subPath(params)) {
+			n, params = nil, nil
+		}
+	}
+	if n != nil {
+		if preflight {
+			// custom OPTIONS handlers take over the preflight
+			cors.handleActual(w, req)
 		}
-		handle.ServeHTTP(w, req)
+		r.serveRoute(w, req, n, params, &d)
 		return
 	}
 
 	// No route matched the incoming request. Try any automatic fallbacks that are enabled.
 
-	if path != "/" && req.Method != http.MethodConnect {
+	var allow string // the methods allowed for OPTIONS requests
+	if req.Method == http.MethodOptions && r.HandleOPTIONS {
+		allow = r.allowed(path, http.MethodOptions)
+		if allow == "" && preflight {
+			// Browsers reject redirected preflights, so they are answered
+			// for the path they would be redirected to instead
+			allow = r.redirectAllowed(path)
+		}
+	}
+
+	if path != "/" && req.Method != http.MethodConnect && !(preflight && allow != "") {
 		// Moved Permanently, request with GET method
 		code := http.StatusMovedPermanently
 		if req.Method != http.MethodGet {
@@ -339,31 +942,38 @@ func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 			code = http.StatusPermanentRedirect
 		}
 
-		// Redirect from (e.g.) `/foo/` to `/foo` (or vice-versa):
-		if r.RedirectTrailingSlash {
-			// using a separate variable here in case we're using RawPath
-			fixedPath := fixSlash(path)
-			if handle, _ := r.lookup(req.Method, fixedPath); handle != nil {
-				req.URL.Path = fixSlash(req.URL.Path)
-				r.redirect(w, req, code)
+		// Redirect from (e.g.) `/foo/` to `/foo` (or vice-versa); the lookup
+		// above already told us whether a handle exists for the other form.
+		if tsr && r.RedirectTrailingSlash {
+			u := r.fixedURL(req.URL, fixSlash)
+			if r.RewritePaths && r.rewrite(w, req, u, &d) {
 				return
 			}
+			d.outcome = OutcomeRedirect
+			location := r.location(req, u)
+			r.emit(Event{Kind: EventRedirectTrailingSlash, Request: req, Status: code, Location: location})
+			r.redirect(w, req, code, u, location)
+			return
 		}
 
-		// Redirect from (e.g.) `/../foo/` to `/foo`:
+		// Redirect from (e.g.) `/../foo/` to `/foo`. An already clean path
+		// was covered by the lookup above.
 		if r.RedirectFixedPath && req.URL.Path != "*" {
-			fixedPath := CleanPath(path)
-			if handle, _ := r.lookup(req.Method, fixedPath); handle != nil {
-				req.URL.Path = fixedPath
-				r.redirect(w, req, code)
-				return
-			}
-
-			if r.RedirectTrailingSlash {
-				fixedPath = fixSlash(fixedPath)
-				if handle, _ := r.lookup(req.Method, fixedPath); handle != nil {
-					req.URL.Path = fixedPath
-					r.redirect(w, req, code)
+			if fixedPath := CleanPath(path); fixedPath != path {
+				fix := CleanPath
+				fixed, _, tsr := r.lookup(req.Method, fixedPath)
+				if fixed == nil && tsr && r.RedirectTrailingSlash {
+					fix = func(p string) string { return fixSlash(CleanPath(p)) }
+				}
+				if fixed != nil || tsr && r.RedirectTrailingSlash {
+					u := r.fixedURL(req.URL, fix)
+					if r.RewritePaths && r.rewrite(w, req, u, &d) {
+						return
+					}
+					d.outcome = OutcomeRedirect
+					location := r.location(req, u)
+					r.emit(Event{Kind: EventRedirectFixedPath, Request: req, Status: code, Location: location})
+					r.redirect(w, req, code, u, location)
 					return
 				}
 			}
@@ -372,8 +982,25 @@ func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 
 	if req.Method == http.MethodOptions && r.HandleOPTIONS {
 		// Handle OPTIONS requests
-		if allow := r.allowed(path, http.MethodOptions); allow != "" {
+		if allow != "" {
+			d.outcome = OutcomeOptions
+			if r.Hooks != nil {
+				status := http.StatusOK
+				if r.GlobalOPTIONS != nil {
+					status = 0
+				} else if preflight {
+					status = http.StatusNoContent
+				}
+				r.emit(Event{Kind: EventOptions, Request: req, Status: status})
+			}
 			w.Header().Set("Allow", allow)
+			if preflight {
+				cors.handlePreflight(w, req, allow)
+				if r.GlobalOPTIONS == nil {
+					w.WriteHeader(http.StatusNoContent)
+					return
+				}
+			}
 			if r.GlobalOPTIONS != nil {
 				r.GlobalOPTIONS.ServeHTTP(w, req)
 			}
@@ -381,9 +1008,15 @@ func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 		}
 	} else if r.HandleMethodNotAllowed { // Handle 405
 		if allow := r.allowed(path, req.Method); allow != "" {
+			d.outcome = OutcomeMethodNotAllowed
+			r.emit(Event{Kind: EventMethodNotAllowed, Request: req, Status: http.StatusMethodNotAllowed})
 			w.Header().Set("Allow", allow)
 			if r.MethodNotAllowed != nil {
 				r.MethodNotAllowed.ServeHTTP(w, req)
+			} else if r.ErrorRenderer != nil {
+				p := newProblem(req, http.StatusMethodNotAllowed)
+				p.Allowed = strings.Split(allow, ", ")
+				r.ErrorRenderer(w, req, p)
 			} else {
 				http.Error(w,
 					http.StatusText(http.StatusMethodNotAllowed),
@@ -395,13 +1028,47 @@ func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	}
 
 	// Handle 404
+	r.emit(Event{Kind: EventNotFound, Request: req, Status: http.StatusNotFound})
 	if r.NotFound != nil {
 		r.NotFound.ServeHTTP(w, req)
+	} else if r.ErrorRenderer != nil {
+		r.ErrorRenderer(w, req, newProblem(req, http.StatusNotFound))
 	} else {
 		http.NotFound(w, req)
 	}
 }
 
+// redirectAllowed returns the methods allowed for OPTIONS requests at the
+// path a request with the given path would be redirected to, if any.
+func (r *Router) redirectAllowed(path string) string {
+	if path == "/" || path == "*" {
+		return ""
+	}
+	if r.RedirectTrailingSlash {
+		if allow := r.allowed(fixSlash(path), http.MethodOptions); allow != "" {
+			return allow
+		}
+	}
+	if r.RedirectFixedPath {
+		if fixedPath := CleanPath(path); fixedPath != path {
+			if allow := r.allowed(fixedPath, http.MethodOptions); allow != "" || !r.RedirectTrailingSlash {
+				return allow
+			}
+			return r.allowed(fixSlash(fixedPath), http.MethodOptions)
+		}
+	}
+	return ""
+}
+
+// routeOf returns the route which would be matched by a request with the
+// given method and path.
+func (r *Router) routeOf(method, path string) string {
+	if n, _, _ := r.lookup(method, path); n != nil {
+		return n.route.Path
+	}
+	return ""
+}
+
 // Adds or a remove a trailing slash from s
 func fixSlash(s string) string {
 	if len(s) > 1 && s[len(s)-1] == '/' {
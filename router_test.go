@@ -7,9 +7,15 @@
 package httprouter
 
 import (
+	"bytes"
+	"crypto/tls"
+	"log"
+	"math/rand"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -31,9 +37,9 @@ func (m *mockResponseWriter) WriteHeader(int) {}
 
 func TestParams(t *testing.T) {
 	ps := Params{
-		Param{"param1", "value1"},
-		Param{"param2", "value2"},
-		Param{"param3", "value3"},
+		Param{Key: "param1", Value: "value1"},
+		Param{Key: "param2", Value: "value2"},
+		Param{Key: "param3", Value: "value3"},
 	}
 	for i := range ps {
 		if val := ps.ByName(ps[i].Key); val != ps[i].Value {
@@ -51,7 +57,7 @@ func TestRouter(t *testing.T) {
 	routed := false
 	router.HandlerFunc(http.MethodGet, "/user/:name", func(w http.ResponseWriter, r *http.Request) {
 		routed = true
-		want := Params{Param{"name", "gopher"}}
+		want := Params{Param{Key: "name", Value: "gopher"}}
 		ps := ParamsFromContext(r.Context())
 		if !reflect.DeepEqual(ps, want) {
 			t.Fatalf("wrong wildcard values: want %v, got %v", want, ps)
@@ -79,7 +85,7 @@ func TestRouterCatchAllParam(t *testing.T) {
 		if ps.CatchAll() != catchAllWant {
 			t.Fatalf("wrong wildcard values: want %v, got %v", catchAllWant, ps)
 		}
-		want := Params{Param{"name", "gopher"}, Param{"$catchAllParam", "are/so/cool"}}
+		want := Params{Param{Key: "name", Value: "gopher"}, Param{Key: "$catchAllParam", Value: "are/so/cool"}}
 		if !reflect.DeepEqual(ps, want) {
 			t.Fatalf("wrong wildcard values: want %v, got %v", want, ps)
 		}
@@ -105,7 +111,7 @@ func TestRouterCatchAllParamNamed(t *testing.T) {
 		if ps.CatchAll() != "" {
 			t.Fatal("expected catch all helper param to be unset")
 		}
-		want := Params{Param{"name", "gopher"}, Param{"rest", "are/so/cool"}}
+		want := Params{Param{Key: "name", Value: "gopher"}, Param{Key: "rest", Value: "are/so/cool"}}
 		if !reflect.DeepEqual(ps, want) {
 			t.Fatalf("wrong wildcard values: want %v, got %v", want, ps)
 		}
@@ -560,6 +566,99 @@ func TestRouterPanicHandler(t *testing.T) {
 	}
 }
 
+func TestRouterPanicReportHandler(t *testing.T) {
+	router := New()
+
+	var report *PanicReport
+	router.PanicHandler = func(_ http.ResponseWriter, _ *http.Request, _ interface{}) {
+		t.Error("PanicHandler called instead of PanicReportHandler")
+	}
+	router.PanicReportHandler = func(_ http.ResponseWriter, _ *http.Request, r *PanicReport) {
+		report = r
+	}
+
+	router.HandlerFunc(http.MethodPut, "/user/:name", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		panic("oops!")
+	})
+
+	req, _ := http.NewRequest(http.MethodPut, "/user/gopher", nil)
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	if report == nil {
+		t.Fatal("panic report handler not called")
+	}
+	if report.Value != "oops!" {
+		t.Errorf("unexpected panic value %v", report.Value)
+	}
+	if report.Route != "/user/:name" {
+		t.Errorf("unexpected route %q", report.Route)
+	}
+	if want := (Params{Param{Key: "name", Value: "gopher"}}); !reflect.DeepEqual(report.Params, want) {
+		t.Errorf("unexpected params %v", report.Params)
+	}
+	if !report.HeadersWritten {
+		t.Error("headers not reported as written")
+	}
+	if !bytes.Contains(report.Stack, []byte("TestRouterPanicReportHandler")) {
+		t.Errorf("stack trace doesn't contain the panicking function:\n%s", report.Stack)
+	}
+}
+
+func TestRouterPanicAbortHandler(t *testing.T) {
+	router := New()
+	router.PanicHandler = func(_ http.ResponseWriter, _ *http.Request, _ interface{}) {
+		t.Error("ErrAbortHandler must not be handled")
+	}
+	router.GET("/abort", func(_ http.ResponseWriter, _ *http.Request) {
+		panic(http.ErrAbortHandler)
+	})
+
+	req, _ := http.NewRequest(http.MethodGet, "/abort", nil)
+	recv := catchPanic(func() {
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	})
+	if recv != http.ErrAbortHandler {
+		t.Fatalf("expected ErrAbortHandler to be re-panicked, got %v", recv)
+	}
+}
+
+func TestRouterDefaultPanicReportHandler(t *testing.T) {
+	var logged bytes.Buffer
+	router := New()
+	router.RecoverPanics = true
+	router.ErrorLog = log.New(&logged, "", 0)
+
+	router.GET("/early", func(_ http.ResponseWriter, _ *http.Request) {
+		panic("early")
+	})
+	router.GET("/late", func(w http.ResponseWriter, _ *http.Request) {
+		w.Write([]byte("partial"))
+		panic("late")
+	})
+
+	req, _ := http.NewRequest(http.MethodGet, "/early", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("unexpected status %d", w.Code)
+	}
+	if !strings.Contains(logged.String(), `panic serving GET /early (route "/early"): early`) {
+		t.Errorf("panic not logged, got %q", logged.String())
+	}
+
+	logged.Reset()
+	req, _ = http.NewRequest(http.MethodGet, "/late", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || w.Body.String() != "partial" {
+		t.Errorf("started response was modified: %d %q", w.Code, w.Body.String())
+	}
+	if !strings.Contains(logged.String(), "late") {
+		t.Errorf("panic not logged, got %q", logged.String())
+	}
+}
+
 func TestRouterEscapedPath(t *testing.T) {
 	router := New()
 	router.UseRawPath = true
@@ -621,3 +720,529 @@ func TestRouterEscapedPath_ButMatchesExpectedEscapedLiterals(t *testing.T) {
 		}
 	}
 }
+
+func TestRouterFreeze(t *testing.T) {
+	routes := [...]string{
+		"/",
+		"/topic/:topicSlug",
+		"/top-stories",
+		"/topics",
+		"/topics/:topicSlug/latest",
+		"/:collectionSlug",
+		"/@:username",
+		"/src/*filepath",
+		"/src/AUTHORS",
+	}
+	requests := [...]string{
+		"/",
+		"/top",
+		"/top-stories",
+		"/topics",
+		"/topics/",
+		"/topics/go/latest",
+		"/topic/news",
+		"/@eduardo",
+		"/%40eduardo",
+		"/src/AUTHORS",
+		"/src/LICENSE",
+		"/nope/nope",
+	}
+
+	var served string
+	router := New()
+	for _, route := range routes {
+		route := route
+		router.GET(route, func(_ http.ResponseWriter, _ *http.Request) {
+			served = route
+		})
+	}
+
+	want := make(map[string]string, len(requests))
+	for _, path := range requests {
+		served = ""
+		r, _ := http.NewRequest(http.MethodGet, path, nil)
+		router.ServeHTTP(httptest.NewRecorder(), r)
+		want[path] = served
+	}
+
+	router.Freeze()
+	if got := len(router.static[http.MethodGet]); got != 4 {
+		t.Fatalf("expected 4 static routes to be indexed, got %d", got)
+	}
+
+	for _, path := range requests {
+		served = ""
+		r, _ := http.NewRequest(http.MethodGet, path, nil)
+		router.ServeHTTP(httptest.NewRecorder(), r)
+		if served != want[path] {
+			t.Errorf("frozen router served %q for %q, tree served %q", served, path, want[path])
+		}
+	}
+
+	// registering a route invalidates the index
+	router.GET("/topics/new", func(_ http.ResponseWriter, _ *http.Request) {
+		served = "/topics/new"
+	})
+	if router.static != nil {
+		t.Fatal("static index was not discarded")
+	}
+	r, _ := http.NewRequest(http.MethodGet, "/topics/new", nil)
+	router.ServeHTTP(httptest.NewRecorder(), r)
+	if served != "/topics/new" {
+		t.Errorf("route registered after Freeze was not served, got %q", served)
+	}
+}
+
+func BenchmarkLookupStatic(b *testing.B) {
+	handlerFunc := func(_ http.ResponseWriter, _ *http.Request) {}
+
+	router := New()
+	router.GET("/", handlerFunc)
+	router.GET("/user/:name", handlerFunc)
+	router.GET("/user/:name/profile", handlerFunc)
+	router.GET("/users/settings/notifications", handlerFunc)
+
+	b.Run("Tree", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_, _, _ = router.lookup(http.MethodGet, "/users/settings/notifications")
+		}
+	})
+
+	router.Freeze()
+	b.Run("Frozen", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_, _, _ = router.lookup(http.MethodGet, "/users/settings/notifications")
+		}
+	})
+}
+
+type nopResponseWriter struct{ header http.Header }
+
+func (w nopResponseWriter) Header() http.Header         { return w.header }
+func (w nopResponseWriter) Write(p []byte) (int, error) { return len(p), nil }
+func (w nopResponseWriter) WriteHeader(int)             {}
+
+func TestRouterServeStaticAllocs(t *testing.T) {
+	router := New()
+	router.GET("/users/settings/notifications", func(_ http.ResponseWriter, req *http.Request) {
+		if RouteFromContext(req.Context()) != nil {
+			t.Error("route stored without metadata, hooks or metrics")
+		}
+	})
+
+	w := nopResponseWriter{make(http.Header)}
+	req := httptest.NewRequest(http.MethodGet, "/users/settings/notifications", nil)
+	if allocs := testing.AllocsPerRun(100, func() { router.ServeHTTP(w, req) }); allocs != 0 {
+		t.Errorf("serving a static route allocated %v times", allocs)
+	}
+}
+
+func BenchmarkServeStatic(b *testing.B) {
+	handlerFunc := func(_ http.ResponseWriter, _ *http.Request) {}
+
+	router := New()
+	router.GET("/", handlerFunc)
+	router.GET("/user/:name", handlerFunc)
+	router.GET("/users/settings/notifications", handlerFunc)
+
+	w := nopResponseWriter{make(http.Header)}
+	req := httptest.NewRequest(http.MethodGet, "/users/settings/notifications", nil)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		router.ServeHTTP(w, req)
+	}
+}
+
+func TestRouterCheckRoute(t *testing.T) {
+	paths := []string{
+		"/cmd/:tool/:box",
+		"/cmd/:tool/:box",
+		"/cmd/:tool/:set",
+		"/cmd/:tool/axe",
+		"/cmd/vet",
+		"/src/*filepath",
+		"/src/*filepathx",
+		"/src/",
+		"/search/:query",
+		"/search/:query/",
+		"/search/:other/",
+		"/user_:name",
+		"/user_x",
+		"/user_:other",
+		"/src2/*filepath/x",
+		"/:foo:bar",
+		"/*",
+		"/*",
+		"/",
+		"/",
+		"noslash",
+	}
+
+	router := New()
+	for _, path := range paths {
+		err := router.CheckRoute(http.MethodGet, path)
+		recv := catchPanic(func() {
+			router.Handler(http.MethodGet, path, fakeHandler(path))
+		})
+		if (err != nil) != (recv != nil) {
+			t.Errorf("CheckRoute for '%s' returned %v, but registering it panicked with %v", path, err, recv)
+		}
+	}
+
+	if err := router.CheckRoute("", "/empty"); err == nil {
+		t.Error("no error for empty method")
+	}
+	if err := router.CheckRoute(http.MethodPost, "/cmd/:tool/:box"); err != nil {
+		t.Errorf("unexpected error for other method: %v", err)
+	}
+}
+
+func TestRouterRewritePaths(t *testing.T) {
+	var path, original string
+	var params Params
+	handler := func(w http.ResponseWriter, req *http.Request) {
+		path = req.URL.Path
+		original = RewriteFromContext(req.Context())
+		params = ParamsFromContext(req.Context())
+	}
+
+	var kinds []EventKind
+	router := New()
+	router.RedirectFixedPath = true
+	router.RewritePaths = true
+	router.Hooks = HooksFunc(func(ev Event) { kinds = append(kinds, ev.Kind) })
+	router.GET("/path", handler)
+	router.GET("/dir/", handler)
+	router.POST("/users/:id", handler)
+
+	tests := []struct {
+		method, reqPath string
+		path, original  string
+		params          Params
+	}{
+		{http.MethodGet, "/path/", "/path", "/path/", nil},
+		{http.MethodGet, "/dir", "/dir/", "/dir", nil},
+		{http.MethodGet, "/../path", "/path", "/../path", nil},
+		{http.MethodGet, "/a/../dir", "/dir/", "/a/../dir", nil},
+		{http.MethodPost, "/users/42/", "/users/42", "/users/42/", Params{{Key: "id", Value: "42"}}},
+		{http.MethodGet, "/path", "/path", "", nil},
+	}
+	for _, tt := range tests {
+		path, original, params, kinds = "", "", nil, nil
+		req, _ := http.NewRequest(tt.method, tt.reqPath, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Errorf("%s %s: unexpected status %d", tt.method, tt.reqPath, w.Code)
+			continue
+		}
+		if path != tt.path || original != tt.original || !reflect.DeepEqual(params, tt.params) {
+			t.Errorf("%s %s: got path %q, original %q and params %v, want %q, %q and %v",
+				tt.method, tt.reqPath, path, original, params, tt.path, tt.original, tt.params)
+		}
+		if req.URL.Path != tt.reqPath {
+			t.Errorf("%s %s: URL of the original request was modified to %q", tt.method, tt.reqPath, req.URL.Path)
+		}
+		wantKinds := []EventKind{EventRewrite, EventMatched}
+		if tt.original == "" {
+			wantKinds = wantKinds[1:]
+		}
+		if !reflect.DeepEqual(kinds, wantKinds) {
+			t.Errorf("%s %s: got events %v, want %v", tt.method, tt.reqPath, kinds, wantKinds)
+		}
+	}
+
+	// paths without a route for the request method are not rewritten
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/42/", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("unexpected status %d", w.Code)
+	}
+}
+
+func TestRouterRedirectLocation(t *testing.T) {
+	handlerFunc := func(_ http.ResponseWriter, _ *http.Request) {}
+
+	tests := []struct {
+		rawPath  bool
+		base     string
+		method   string
+		target   string
+		code     int
+		location string
+	}{
+		// trailing slash
+		{false, "", http.MethodGet, "/path/", 301, "/path"},
+		{false, "", http.MethodGet, "/path/?q=1&b=%2F", 301, "/path?q=1&b=%2F"},
+		{false, "", http.MethodGet, "/dir?", 301, "/dir/?"},
+		{false, "", http.MethodGet, "/files/a%20b/", 301, "/files/a%20b"},
+		{false, "", http.MethodPost, "/files/a%20b/?x", 308, "/files/a%20b?x"},
+		{true, "", http.MethodGet, "/path/?q=1", 301, "/path?q=1"},
+		{true, "", http.MethodGet, "/files/a%2Fb/", 301, "/files/a%2Fb"},
+		{true, "", http.MethodGet, "/files/a%2Fb/?q=%2F", 301, "/files/a%2Fb?q=%2F"},
+		{false, "http://example.com", http.MethodGet, "/dir?q=1", 301, "http://example.com/dir/?q=1"},
+		{false, "https://example.com", http.MethodGet, "/files/a%20b/", 301, "https://example.com/files/a%20b"},
+		{true, "http://example.com", http.MethodGet, "/files/a%2Fb/?q=1", 301, "http://example.com/files/a%2Fb?q=1"},
+		{true, "https://example.com", http.MethodGet, "/path/", 301, "https://example.com/path"},
+		{false, "https://example.com/app/", http.MethodGet, "/path/?q", 301, "https://example.com/app/path?q"},
+
+		// fixed path
+		{false, "", http.MethodGet, "/../path?q=1", 301, "/path?q=1"},
+		{false, "", http.MethodGet, "//files/a%20b", 301, "/files/a%20b"},
+		{false, "", http.MethodGet, "/x/../dir?q", 301, "/dir/?q"},
+		{true, "", http.MethodGet, "//files/a%2Fb?q=1", 301, "/files/a%2Fb?q=1"},
+		{true, "", http.MethodGet, "/x/../files/a%2Fb/", 301, "/files/a%2Fb"},
+		{false, "http://example.com", http.MethodGet, "//path?q=1", 301, "http://example.com/path?q=1"},
+		{false, "https://example.com", http.MethodPost, "/x/../files/a%20b", 308, "https://example.com/files/a%20b"},
+		{true, "http://example.com", http.MethodGet, "//files/a%2Fb", 301, "http://example.com/files/a%2Fb"},
+		{true, "https://example.com", http.MethodGet, "/x/../dir", 301, "https://example.com/dir/"},
+
+		// escaped slashes only match in raw mode
+		{false, "", http.MethodGet, "/files/a%2Fb/", 404, ""},
+	}
+	for _, tt := range tests {
+		router := New()
+		router.UseRawPath = tt.rawPath
+		router.RedirectBaseURL = tt.base
+		router.RedirectFixedPath = true
+		router.GET("/path", handlerFunc)
+		router.GET("/dir/", handlerFunc)
+		router.GET("/files/:name", handlerFunc)
+		router.POST("/files/:name", handlerFunc)
+
+		req := httptest.NewRequest(tt.method, tt.target, nil)
+		// the host and scheme of the request are never used
+		req.Host = "evil.example"
+		req.TLS = &tls.ConnectionState{}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != tt.code {
+			t.Errorf("%s %s (raw %t): unexpected status %d, want %d", tt.method, tt.target, tt.rawPath, w.Code, tt.code)
+		}
+		if location := w.Header().Get("Location"); location != tt.location {
+			t.Errorf("%s %s (raw %t): unexpected location %q, want %q", tt.method, tt.target, tt.rawPath, location, tt.location)
+		}
+		if req.URL.RequestURI() != tt.target {
+			t.Errorf("%s %s (raw %t): URL of the request was modified to %q", tt.method, tt.target, tt.rawPath, req.URL.RequestURI())
+		}
+	}
+
+	// the redirect handler receives the target URL
+	var got *url.URL
+	var gotCode int
+	router := New()
+	router.UseRawPath = true
+	router.GET("/files/:name", handlerFunc)
+	router.RedirectHandler = func(w http.ResponseWriter, req *http.Request, code int) {
+		got, gotCode = req.URL, code
+	}
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/files/a%2Fb/?q=1", nil))
+	if got == nil || got.Path != "/files/a/b" || got.EscapedPath() != "/files/a%2Fb" || got.RawQuery != "q=1" || gotCode != 301 {
+		t.Errorf("unexpected redirect to %v with %d", got, gotCode)
+	}
+}
+
+func TestRouterUnescapePathValues(t *testing.T) {
+	var params Params
+	handler := func(w http.ResponseWriter, req *http.Request) {
+		params = ParamsFromContext(req.Context())
+	}
+
+	router := New()
+	router.UseRawPath = true
+	router.UnescapePathValues = true
+	router.GET("/files/:dir/*path", handler)
+	router.Redirect(http.MethodGet, "/old/:name", "/files/{name}/x", 0)
+
+	tests := []struct {
+		path   string
+		values Params
+	}{
+		{"/files/a%2Fb/c%20d/e", Params{{"dir", "a/b", "a%2Fb"}, {"path", "c d/e", "c%20d/e"}}},
+		{"/files/a%2Fb/c", Params{{"dir", "a/b", "a%2Fb"}, {"path", "c", ""}}},
+		// without escapes RawPath is empty, so the values are as matched
+		{"/files/a/b", Params{{"dir", "a", ""}, {"path", "b", ""}}},
+	}
+	for _, tt := range tests {
+		params = nil
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if !reflect.DeepEqual(params, tt.values) {
+			t.Errorf("%s: got params %v, want %v", tt.path, params, tt.values)
+		}
+	}
+	if raw := params.RawByName("dir"); raw != "a" {
+		t.Errorf("got raw value %q of an unchanged value", raw)
+	}
+
+	// redirect rules keep the escaping
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/old/a%2Fb", nil))
+	if location := w.Header().Get("Location"); location != "/files/a%2Fb/x" {
+		t.Errorf("unexpected location %q", location)
+	}
+
+	// the params of a mount prefix are unescaped as well
+	parent := New()
+	parent.UseRawPath = true
+	parent.UnescapePathValues = true
+	parent.Mount("/tenants/:tenant", router)
+	params = nil
+	parent.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/tenants/a%20b/files/c%2Fd/e", nil))
+	if want := (Params{{"tenant", "a b", "a%20b"}, {"dir", "c/d", "c%2Fd"}, {"path", "e", ""}}); !reflect.DeepEqual(params, want) {
+		t.Errorf("got params %v below mount, want %v", params, want)
+	}
+	parent.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/tenants/a%2Fb/files/c/d", nil))
+	if want := (Params{{"tenant", "a/b", "a%2Fb"}, {"dir", "c", ""}, {"path", "d", ""}}); !reflect.DeepEqual(params, want) {
+		t.Errorf("got params %v below mount with only the prefix escaped, want %v", params, want)
+	}
+
+	// the option has no effect without UseRawPath
+	router.UseRawPath = false
+	params = nil
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/files/a%20b/c", nil))
+	if want := (Params{{"dir", "a b", ""}, {"path", "c", ""}}); !reflect.DeepEqual(params, want) {
+		t.Errorf("got params %v without UseRawPath, want %v", params, want)
+	}
+}
+
+func TestRouterIgnoreTrailingSlash(t *testing.T) {
+	var route string
+	var params Params
+	handler := func(w http.ResponseWriter, req *http.Request) {
+		route = RouteFromContext(req.Context()).Path
+		params = ParamsFromContext(req.Context())
+	}
+
+	var kinds []EventKind
+	router := New()
+	router.IgnoreTrailingSlash = true
+	router.Hooks = HooksFunc(func(ev Event) { kinds = append(kinds, ev.Kind) })
+	router.GET("/", handler)
+	router.GET("/foo", handler)
+	router.GET("/dir/", handler)
+	router.GET("/users/:id", handler)
+	router.GET("/strict", handler, WithStrictSlash())
+	router.GET("/meta", handler, WithMetadata("strictSlash", true))
+	router.GET("/both", handler)
+	router.GET("/both/", handler)
+
+	tests := []struct {
+		path   string
+		code   int
+		route  string
+		params Params
+	}{
+		{"/foo", http.StatusOK, "/foo", nil},
+		{"/foo/", http.StatusOK, "/foo", nil},
+		{"/dir", http.StatusOK, "/dir/", nil},
+		{"/dir/", http.StatusOK, "/dir/", nil},
+		{"/users/42/", http.StatusOK, "/users/:id", Params{{Key: "id", Value: "42"}}},
+		{"/strict", http.StatusOK, "/strict", nil},
+		{"/strict/", http.StatusMovedPermanently, "", nil},
+		{"/meta/", http.StatusOK, "/meta", nil},
+		{"/both", http.StatusOK, "/both", nil},
+		{"/both/", http.StatusOK, "/both/", nil},
+		{"/", http.StatusOK, "/", nil},
+		{"/foo//", http.StatusNotFound, "", nil},
+	}
+	for _, tt := range tests {
+		route, params, kinds = "", nil, nil
+		req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != tt.code || route != tt.route || !reflect.DeepEqual(params, tt.params) {
+			t.Errorf("%s: got %d, route %q and params %v, want %d, %q and %v",
+				tt.path, w.Code, route, params, tt.code, tt.route, tt.params)
+		}
+		if tt.code == http.StatusOK && !reflect.DeepEqual(kinds, []EventKind{EventMatched}) {
+			t.Errorf("%s: got events %v", tt.path, kinds)
+		}
+		if req.URL.Path != tt.path {
+			t.Errorf("%s: URL of the request was modified to %q", tt.path, req.URL.Path)
+		}
+	}
+
+	// the other methods of the route are allowed without the slash as well
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/foo/", nil))
+	if w.Code != http.StatusMethodNotAllowed || w.Header().Get("Allow") != "GET, OPTIONS" {
+		t.Errorf("got %d and Allow %q", w.Code, w.Header().Get("Allow"))
+	}
+
+	if rt, ps, tsr := router.Lookup(http.MethodGet, "/users/7/"); rt == nil || rt.Path != "/users/:id" || ps.ByName("id") != "7" || tsr {
+		t.Errorf("unexpected lookup result %v, %v, %t", rt, ps, tsr)
+	}
+
+	// off by default, redirecting as before
+	router.IgnoreTrailingSlash = false
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/foo/", nil))
+	if w.Code != http.StatusMovedPermanently || w.Header().Get("Location") != "/foo" {
+		t.Errorf("got %d to %q without IgnoreTrailingSlash", w.Code, w.Header().Get("Location"))
+	}
+}
+
+func TestRouterTrailingSlashRedirectDifferential(t *testing.T) {
+	handlerFunc := func(_ http.ResponseWriter, _ *http.Request) {}
+	check := func(router *Router, routes []string, path string) {
+		n, _, tsr := router.lookup(http.MethodGet, path)
+		if n != nil || path == "/" {
+			return
+		}
+		fixed, _, _ := router.lookup(http.MethodGet, fixSlash(path))
+		if tsr != (fixed != nil) {
+			t.Errorf("routes %v, GET %q: got tsr %t, but %q matches: %t", routes, path, tsr, fixSlash(path), fixed != nil)
+		}
+	}
+
+	for _, tt := range []struct {
+		routes []string
+		path   string
+	}{
+		{[]string{"/a/:x", "/b/:x/", "/"}, "//b"},
+		{[]string{"/:x/ab", "/:x/", "/"}, "//a"},
+		{[]string{"/:x/"}, "//c/c/"},
+	} {
+		router := New()
+		for _, route := range tt.routes {
+			router.GET(route, handlerFunc)
+		}
+		check(router, tt.routes, tt.path)
+	}
+
+	// random routes and requests
+	rnd := rand.New(rand.NewSource(1))
+	segments := []string{"a", "b", "ab", ":x", ":y", "*"}
+	requestSegments := []string{"", "a", "b", "ab", "c", "abc"}
+	for i := 0; i < 2000; i++ {
+		router := New()
+		var routes []string
+		for k := rnd.Intn(5); k >= 0; k-- {
+			var route string
+			for s := rnd.Intn(4); s >= 0; s-- {
+				segment := segments[rnd.Intn(len(segments))]
+				route += "/" + segment
+				if segment == "*" {
+					break
+				}
+			}
+			if rnd.Intn(3) == 0 && !strings.HasSuffix(route, "*") {
+				route += "/"
+			}
+			if catchPanic(func() { router.GET(route, handlerFunc) }) == nil {
+				routes = append(routes, route)
+			}
+		}
+		for q := 0; q < 20; q++ {
+			var path string
+			for s := rnd.Intn(4); s >= 0; s-- {
+				path += "/" + requestSegments[rnd.Intn(len(requestSegments))]
+			}
+			check(router, routes, path)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package httprouter
+
+import (
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Errors of requests rejected because of the values of their parameters, see
+// Router.SafePathValues.
+var (
+	ErrPathTraversal    = errors.New("httprouter: path traversal in parameter")
+	ErrControlCharacter = errors.New("httprouter: control character in parameter")
+)
+
+// ParamError is the error of a request rejected because of the value of a
+// parameter.
+type ParamError struct {
+	Key string
+	Err error
+}
+
+func (e *ParamError) Error() string {
+	key := e.Key
+	if key == catchAllParam {
+		key = "*"
+	}
+	return e.Err.Error() + " " + strconv.Quote(key)
+}
+
+// Unwrap returns the reason the value was rejected.
+func (e *ParamError) Unwrap() error {
+	return e.Err
+}
+
+// sanitizeParams checks the values of the params matched by a route with the
+// given path. Values escaping the path they are found at, by too many ".."
+// segments, or containing control characters are rejected. If raw is set,
+// the values were matched against the escaped path, and are checked unescaped
+// as well. The values of catch-alls are cleaned, see CleanPath. The values of
+// the catch-all of a mount are left to the mounted handler. The cleaned params
+// are returned, or nil if no value was cleaned.
+func sanitizeParams(params Params, path string, raw, mount bool) (Params, error) {
+	catchAll := -1
+	if i := strings.LastIndexByte(path, '/'); i >= 0 && strings.HasPrefix(path[i+1:], "*") {
+		catchAll = len(params) - 1
+	}
+
+	var safe Params
+	for i, p := range params {
+		if i == catchAll && mount {
+			continue
+		}
+		if err := checkValue(p.Value); err != nil {
+			return nil, &ParamError{Key: p.Key, Err: err}
+		}
+		if raw {
+			if unescaped, err := url.PathUnescape(p.Value); err == nil && unescaped != p.Value {
+				if err := checkValue(unescaped); err != nil {
+					return nil, &ParamError{Key: p.Key, Err: err}
+				}
+			}
+		}
+
+		if i != catchAll {
+			continue
+		}
+		if cleaned := CleanPath("/" + p.Value)[1:]; cleaned != p.Value {
+			if safe == nil {
+				safe = make(Params, len(params))
+				copy(safe, params)
+			}
+			safe[i].Value, safe[i].Raw = cleaned, p.raw()
+		}
+	}
+	return safe, nil
+}
+
+// checkValue checks a parameter value for control characters and for ".."
+// segments leading above the path the value is found at. Backslashes are
+// taken for separators as well, as by some file systems.
+func checkValue(value string) error {
+	depth := 0
+	start := 0
+	for i := 0; i <= len(value); i++ {
+		if i < len(value) {
+			if c := value[i]; c < 0x20 || c == 0x7f {
+				return ErrControlCharacter
+			}
+			if value[i] != '/' && value[i] != '\\' {
+				continue
+			}
+		}
+
+		switch value[start:i] {
+		case "", ".":
+		case "..":
+			if depth--; depth < 0 {
+				return ErrPathTraversal
+			}
+		default:
+			depth++
+		}
+		start = i + 1
+	}
+	return nil
+}
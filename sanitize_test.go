@@ -0,0 +1,164 @@
+package httprouter
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCheckValue(t *testing.T) {
+	tests := []struct {
+		value string
+		err   error
+	}{
+		{"", nil},
+		{"a/b/c", nil},
+		{"a/../b", nil},
+		{"a/b/../../c", nil},
+		{"./a", nil},
+		{"...", nil},
+		{"..a", nil},
+		{"..", ErrPathTraversal},
+		{"../etc/passwd", ErrPathTraversal},
+		{"a/../../etc/passwd", ErrPathTraversal},
+		{"a//../..", ErrPathTraversal},
+		{`..\windows`, ErrPathTraversal},
+		{"a\x00b", ErrControlCharacter},
+		{"a\nb", ErrControlCharacter},
+		{"a\x7fb", ErrControlCharacter},
+	}
+	for _, tt := range tests {
+		if err := checkValue(tt.value); err != tt.err {
+			t.Errorf("%q: got error %v, want %v", tt.value, err, tt.err)
+		}
+	}
+}
+
+func TestRouterSafePathValues(t *testing.T) {
+	var params Params
+	handler := func(w http.ResponseWriter, req *http.Request) {
+		params = ParamsFromContext(req.Context())
+	}
+
+	tests := []struct {
+		rawPath bool
+		path    string
+		code    int
+		values  Params
+	}{
+		{false, "/files/docs/a/b", http.StatusOK, Params{{Key: "dir", Value: "docs"}, {Key: "path", Value: "a/b"}}},
+		{false, "/files/docs/a//./b/", http.StatusOK, Params{{Key: "dir", Value: "docs"}, {Key: "path", Value: "a/b/", Raw: "a//./b/"}}},
+		{false, "/files/docs/a/../b", http.StatusOK, Params{{Key: "dir", Value: "docs"}, {Key: "path", Value: "b", Raw: "a/../b"}}},
+		{false, "/files/docs/../../etc/passwd", http.StatusBadRequest, nil},
+		{false, "/files/docs/a%2F..%2F..%2Fetc", http.StatusBadRequest, nil},
+		{false, "/files/%2E%2E/a", http.StatusBadRequest, nil},
+		{false, "/files/docs/a%00b", http.StatusBadRequest, nil},
+		{false, "/files/a%0Ab/c", http.StatusBadRequest, nil},
+		{true, "/files/docs/..%2F..%2Fetc", http.StatusBadRequest, nil},
+		{true, "/files/..%2F/a", http.StatusBadRequest, nil},
+		{true, "/files/a%2Fb/c%00", http.StatusBadRequest, nil},
+		{true, "/files/a%2Fb/c//d", http.StatusOK, Params{{Key: "dir", Value: "a%2Fb"}, {Key: "path", Value: "c/d", Raw: "c//d"}}},
+	}
+	for _, tt := range tests {
+		router := New()
+		router.UseRawPath = tt.rawPath
+		router.SafePathValues = true
+		router.GET("/files/:dir/*path", handler)
+
+		params = nil
+		// keep the dot segments of the path, like the server
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.URL, _ = url.Parse(tt.path)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != tt.code || !reflect.DeepEqual(params, tt.values) {
+			t.Errorf("%s (raw %t): got %d and params %v, want %d and %v", tt.path, tt.rawPath, w.Code, params, tt.code, tt.values)
+		}
+	}
+
+	// the values as matched are still available
+	router := New()
+	router.SafePathValues = true
+	router.GET("/files/*path", handler)
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/files/a//b", nil))
+	if params.ByName("path") != "a/b" || params.RawByName("path") != "a//b" {
+		t.Errorf("got path %q and raw path %q", params.ByName("path"), params.RawByName("path"))
+	}
+}
+
+func TestRouterSafePathValuesUnescaped(t *testing.T) {
+	var params Params
+	router := New()
+	router.UseRawPath = true
+	router.UnescapePathValues = true
+	router.SafePathValues = true
+	router.GET("/files/*path", func(w http.ResponseWriter, req *http.Request) {
+		params = ParamsFromContext(req.Context())
+	})
+
+	// the values are checked once unescaped, not unescaped again
+	tests := []struct {
+		path  string
+		code  int
+		value string
+	}{
+		{"/files/%252e%252e%2Fx", http.StatusOK, "%2e%2e/x"},
+		{"/files/a%2F..%2F..%2Fetc", http.StatusBadRequest, ""},
+		{"/files/a%2Fb%00", http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		params = nil
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.URL, _ = url.Parse(tt.path)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != tt.code || params.ByName("path") != tt.value {
+			t.Errorf("%s: got %d and path %q, want %d and %q", tt.path, w.Code, params.ByName("path"), tt.code, tt.value)
+		}
+	}
+}
+
+func TestRouterSafePathValuesRejection(t *testing.T) {
+	var events []Event
+	router := New()
+	router.SafePathValues = true
+	router.Hooks = HooksFunc(func(ev Event) { events = append(events, ev) })
+	router.GET("/files/*path", func(w http.ResponseWriter, req *http.Request) {
+		t.Error("handler called")
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/files/a/%2E%2E/%2E%2E/etc", nil))
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), `path traversal in parameter "path"`) {
+		t.Errorf("unexpected reply %d %q", w.Code, w.Body.String())
+	}
+	if len(events) != 1 || events[0].Kind != EventRejected || !errors.Is(events[0].Err, ErrPathTraversal) {
+		t.Errorf("unexpected events %+v", events)
+	}
+
+	// the remaining path of a mount is left to the mounted handler
+	var remaining string
+	parent := New()
+	parent.SafePathValues = true
+	parent.Mount("/static/:version", http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		remaining = req.URL.Path
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.URL.Path = "/static/v1/a/../b"
+	w = httptest.NewRecorder()
+	parent.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || remaining != "/a/../b" {
+		t.Errorf("got %d and remaining path %q", w.Code, remaining)
+	}
+	req.URL.Path = "/static/../x"
+	w = httptest.NewRecorder()
+	parent.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("got %d for traversal in mount prefix", w.Code)
+	}
+}
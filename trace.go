@@ -0,0 +1,309 @@
+package httprouter
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	mrand "math/rand"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TraceID is the identifier of a trace, see the W3C Trace Context
+// specification.
+type TraceID [16]byte
+
+// String returns the lowercase hex encoding of the id.
+func (id TraceID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// SpanID is the identifier of a span, see the W3C Trace Context
+// specification.
+type SpanID [8]byte
+
+// String returns the lowercase hex encoding of the id.
+func (id SpanID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// Span describes the handling of a single request.
+type Span struct {
+	TraceID TraceID
+	SpanID  SpanID
+
+	// The id of the span of the caller, or zero if the span starts a trace.
+	ParentID SpanID
+
+	// Whether the trace is sampled. Only sampled spans are exported.
+	Sampled bool
+
+	// The tracestate header received from the caller, passed on unchanged.
+	TraceState string
+
+	// The method and the matched route path, like "GET /users/:id", or only
+	// the method if no route matched.
+	Name string
+
+	Method string
+	Path   string
+
+	// The matched route path, if any.
+	Route string
+
+	// The routing decisions made for the request, in order.
+	Events []EventKind
+
+	// The status code of the response.
+	Status int
+
+	Start time.Time
+	End   time.Time
+}
+
+// TraceParent returns the traceparent header value identifying s as the
+// parent of outgoing requests.
+func (s *Span) TraceParent() string {
+	flags := "00"
+	if s.Sampled {
+		flags = "01"
+	}
+	return "00-" + s.TraceID.String() + "-" + s.SpanID.String() + "-" + flags
+}
+
+// Inject adds the traceparent and tracestate headers of s to the header of
+// an outgoing request, continuing the trace downstream.
+func (s *Span) Inject(header http.Header) {
+	header.Set("traceparent", s.TraceParent())
+	if s.TraceState != "" {
+		header.Set("tracestate", s.TraceState)
+	} else {
+		header.Del("tracestate")
+	}
+}
+
+type spanKey struct{}
+
+// SpanKey is the request context key under which the span of the request is
+// stored.
+var SpanKey = spanKey{}
+
+// SpanFromContext pulls the span of the request from a request context,
+// or returns nil if none is present.
+func SpanFromContext(ctx context.Context) *Span {
+	s, _ := ctx.Value(SpanKey).(*Span)
+	return s
+}
+
+// SpanExporter receives finished spans.
+type SpanExporter interface {
+	ExportSpan(*Span)
+}
+
+// MemoryExporter is a SpanExporter keeping all spans in memory, e.g. for
+// tests.
+type MemoryExporter struct {
+	mu    sync.Mutex
+	spans []*Span
+}
+
+// ExportSpan stores s.
+func (e *MemoryExporter) ExportSpan(s *Span) {
+	e.mu.Lock()
+	e.spans = append(e.spans, s)
+	e.mu.Unlock()
+}
+
+// Spans returns the stored spans in the order they finished.
+func (e *MemoryExporter) Spans() []*Span {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]*Span(nil), e.spans...)
+}
+
+// Reset removes all stored spans.
+func (e *MemoryExporter) Reset() {
+	e.mu.Lock()
+	e.spans = nil
+	e.mu.Unlock()
+}
+
+// Tracer records a span for every request, continuing the trace of the
+// caller as described by the W3C Trace Context traceparent and tracestate
+// headers. Spans are named after the matched route.
+//
+// The tracer is both a middleware starting and finishing the spans and the
+// hooks of the router naming them:
+//
+//	tracer := httprouter.NewTracer(exporter)
+//	router.Hooks = tracer
+//	log.Fatal(http.ListenAndServe(":8080", tracer.Middleware(router)))
+type Tracer struct {
+	// Receives the finished spans of sampled traces.
+	Exporter SpanExporter
+
+	// Decides whether a trace started by the tracer is sampled. Traces
+	// continued from a caller keep its decision.
+	// If nil, all traces are sampled.
+	Sampler func(*http.Request) bool
+}
+
+// NewTracer returns a new tracer exporting spans to the given exporter.
+func NewTracer(exporter SpanExporter) *Tracer {
+	return &Tracer{Exporter: exporter}
+}
+
+// Middleware returns a handler recording a span for every request served by
+// next. The span is stored in the request context.
+func (t *Tracer) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		span := t.start(req)
+		rw, w := wrapWriter(w)
+		defer t.finish(span, rw)
+
+		next.ServeHTTP(w, req.WithContext(context.WithValue(req.Context(), SpanKey, span)))
+	})
+}
+
+func (t *Tracer) start(req *http.Request) *Span {
+	span := &Span{
+		Name:   req.Method,
+		Method: req.Method,
+		Path:   req.URL.Path,
+		Start:  time.Now(),
+	}
+
+	if traceID, parentID, sampled, ok := parseTraceParent(req.Header.Get("traceparent")); ok {
+		span.TraceID, span.ParentID, span.Sampled = traceID, parentID, sampled
+		span.TraceState = parseTraceState(req.Header["Tracestate"])
+	} else {
+		newID(span.TraceID[:])
+		span.Sampled = t.Sampler == nil || t.Sampler(req)
+	}
+	newID(span.SpanID[:])
+	return span
+}
+
+// randRead reads random bytes for ids; it is replaced in tests.
+var randRead = rand.Read
+
+// fallbackRand generates ids if the system random source fails.
+var fallbackRand = struct {
+	sync.Mutex
+	*mrand.Rand
+}{Rand: mrand.New(mrand.NewSource(time.Now().UnixNano()))}
+
+// newID fills id with random bytes. If the system random source fails, the
+// bytes are taken from a pseudo-random generator instead, as a trace id needs
+// to be unique but not unpredictable. An id of only zeros is invalid, so it
+// is never generated.
+func newID(id []byte) {
+	if _, err := randRead(id); err != nil {
+		fallbackRand.Lock()
+		fallbackRand.Read(id)
+		fallbackRand.Unlock()
+	}
+	for _, b := range id {
+		if b != 0 {
+			return
+		}
+	}
+	id[len(id)-1] = 1
+}
+
+func (t *Tracer) finish(span *Span, w *responseWriter) {
+	rcv := recover()
+	span.End = time.Now()
+	span.Status = w.status
+	if rcv != nil {
+		span.Status = http.StatusInternalServerError
+	} else if span.Status == 0 {
+		span.Status = http.StatusOK
+	}
+
+	if span.Sampled && t.Exporter != nil {
+		t.Exporter.ExportSpan(span)
+	}
+	if rcv != nil {
+		panic(rcv)
+	}
+}
+
+// OnEvent names the span of the request after the matched route and records
+// the routing decisions.
+func (t *Tracer) OnEvent(ev Event) {
+	span := SpanFromContext(ev.Request.Context())
+	if span == nil {
+		return
+	}
+	if ev.Route != nil {
+		span.Route = ev.Route.Path
+		span.Name = span.Method + " " + ev.Route.Path
+	}
+	span.Events = append(span.Events, ev.Kind)
+}
+
+// parseTraceParent parses a traceparent header. Versions above 00 are parsed
+// like version 00, ignoring any additional fields.
+func parseTraceParent(s string) (traceID TraceID, parentID SpanID, sampled bool, ok bool) {
+	s = strings.TrimSpace(s)
+	if len(s) < 55 || s[2] != '-' || s[35] != '-' || s[52] != '-' {
+		return
+	}
+	version := s[:2]
+	if !isLowerHex(version) || version == "ff" {
+		return
+	}
+	if len(s) > 55 && (version == "00" || s[55] != '-') {
+		return
+	}
+	if !isLowerHex(s[3:35]) || !isLowerHex(s[36:52]) || !isLowerHex(s[53:55]) {
+		return
+	}
+
+	hex.Decode(traceID[:], []byte(s[3:35]))
+	hex.Decode(parentID[:], []byte(s[36:52]))
+	if traceID == (TraceID{}) || parentID == (SpanID{}) {
+		return
+	}
+	var flags [1]byte
+	hex.Decode(flags[:], []byte(s[53:55]))
+	return traceID, parentID, flags[0]&1 == 1, true
+}
+
+// parseTraceState joins the tracestate headers of a request into one value.
+// Empty list members are dropped. If the list is invalid, it is discarded as
+// a whole.
+func parseTraceState(headers []string) string {
+	var members []string
+	seen := make(map[string]bool)
+	for _, header := range headers {
+		for _, member := range strings.Split(header, ",") {
+			member = strings.TrimSpace(member)
+			if member == "" {
+				continue
+			}
+			eq := strings.IndexByte(member, '=')
+			if eq <= 0 || eq == len(member)-1 || seen[member[:eq]] {
+				return ""
+			}
+			seen[member[:eq]] = true
+			members = append(members, member)
+		}
+	}
+	if len(members) > 32 {
+		return ""
+	}
+	return strings.Join(members, ",")
+}
+
+func isLowerHex(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; !('0' <= c && c <= '9' || 'a' <= c && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,155 @@
+package httprouter
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestTracer(t *testing.T) {
+	exporter := new(MemoryExporter)
+	tracer := NewTracer(exporter)
+
+	router := New()
+	router.Hooks = tracer
+	router.GET("/users/:id", func(w http.ResponseWriter, req *http.Request) {
+		span := SpanFromContext(req.Context())
+		if span == nil {
+			t.Fatal("no span in request context")
+		}
+		header := make(http.Header)
+		span.Inject(header)
+		w.Header().Set("X-Downstream", header.Get("traceparent"))
+		w.Header().Set("X-Tracestate", header.Get("tracestate"))
+		w.WriteHeader(http.StatusAccepted)
+	})
+	handler := tracer.Middleware(router)
+
+	req := httptest.NewRequest(http.MethodGet, "/users/42", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	req.Header.Add("tracestate", "rojo=00f067aa0ba902b7")
+	req.Header.Add("tracestate", "congo=t61rcWkgMzE")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	spans := exporter.Spans()
+	if len(spans) != 1 {
+		t.Fatalf("got %d spans, want 1", len(spans))
+	}
+	span := spans[0]
+	if span.Name != "GET /users/:id" || span.Route != "/users/:id" || span.Path != "/users/42" {
+		t.Errorf("unexpected span name %q, route %q and path %q", span.Name, span.Route, span.Path)
+	}
+	if span.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" || span.ParentID.String() != "00f067aa0ba902b7" {
+		t.Errorf("trace not continued: trace %s, parent %s", span.TraceID, span.ParentID)
+	}
+	if span.SpanID == span.ParentID || span.SpanID == (SpanID{}) {
+		t.Errorf("unexpected span id %s", span.SpanID)
+	}
+	if !span.Sampled || span.Status != http.StatusAccepted || span.End.Before(span.Start) {
+		t.Errorf("unexpected span %+v", span)
+	}
+	if !reflect.DeepEqual(span.Events, []EventKind{EventMatched}) {
+		t.Errorf("unexpected events %v", span.Events)
+	}
+
+	want := "00-4bf92f3577b34da6a3ce929d0e0e4736-" + span.SpanID.String() + "-01"
+	if got := w.Header().Get("X-Downstream"); got != want {
+		t.Errorf("injected traceparent %q, want %q", got, want)
+	}
+	if got := w.Header().Get("X-Tracestate"); got != "rojo=00f067aa0ba902b7,congo=t61rcWkgMzE" {
+		t.Errorf("unexpected injected tracestate %q", got)
+	}
+
+	// a router generated reply starts a new trace named after the method
+	exporter.Reset()
+	tracer.Sampler = func(*http.Request) bool { return true }
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/missing", nil))
+	spans = exporter.Spans()
+	if len(spans) != 1 {
+		t.Fatalf("got %d spans, want 1", len(spans))
+	}
+	if span := spans[0]; span.Name != "GET" || span.Status != http.StatusNotFound ||
+		span.ParentID != (SpanID{}) || span.TraceID == (TraceID{}) ||
+		!reflect.DeepEqual(span.Events, []EventKind{EventNotFound}) {
+		t.Errorf("unexpected span %+v", span)
+	}
+
+	// unsampled traces are not exported
+	exporter.Reset()
+	req = httptest.NewRequest(http.MethodGet, "/users/42", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if len(exporter.Spans()) != 0 {
+		t.Error("unsampled span was exported")
+	}
+}
+
+func TestParseTraceParent(t *testing.T) {
+	tests := []struct {
+		header  string
+		ok      bool
+		sampled bool
+	}{
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", true, true},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00", true, false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-03", true, true},
+		{"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", true, true},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", false, false},
+		{"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", false, false},
+		{"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", false, false},
+		{"00-00000000000000000000000000000000-00f067aa0ba902b7-01", false, false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", false, false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7", false, false},
+		{"", false, false},
+	}
+	for _, tt := range tests {
+		_, _, sampled, ok := parseTraceParent(tt.header)
+		if ok != tt.ok || sampled != tt.sampled {
+			t.Errorf("parseTraceParent(%q) = %v, %v, want %v, %v", tt.header, sampled, ok, tt.sampled, tt.ok)
+		}
+	}
+}
+
+func TestParseTraceState(t *testing.T) {
+	tests := []struct {
+		headers []string
+		want    string
+	}{
+		{[]string{"a=1"}, "a=1"},
+		{[]string{"a=1, ,b=2", "c=3"}, "a=1,b=2,c=3"},
+		{[]string{"a=1,a=2"}, ""},
+		{[]string{"a"}, ""},
+		{nil, ""},
+	}
+	for _, tt := range tests {
+		if got := parseTraceState(tt.headers); got != tt.want {
+			t.Errorf("parseTraceState(%q) = %q, want %q", tt.headers, got, tt.want)
+		}
+	}
+}
+
+func TestTracerRandFailure(t *testing.T) {
+	defer func(read func([]byte) (int, error)) { randRead = read }(randRead)
+	randRead = func(b []byte) (int, error) { return 0, errors.New("no entropy") }
+
+	tracer := NewTracer(nil)
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	a, b := tracer.start(req), tracer.start(req)
+	if a.TraceID == (TraceID{}) || a.SpanID == (SpanID{}) || a.TraceID == b.TraceID {
+		t.Errorf("unexpected ids %v %v and %v", a.TraceID, a.SpanID, b.TraceID)
+	}
+
+	// ids of only zeros are invalid
+	randRead = func(b []byte) (int, error) {
+		for i := range b {
+			b[i] = 0
+		}
+		return len(b), nil
+	}
+	if span := tracer.start(req); span.TraceID == (TraceID{}) || span.SpanID == (SpanID{}) {
+		t.Errorf("unexpected ids %v %v", span.TraceID, span.SpanID)
+	}
+}
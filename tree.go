@@ -8,7 +8,6 @@ package httprouter
 
 import (
 	"net/http"
-	"net/url"
 	"strings"
 )
 
@@ -33,6 +32,12 @@ type node struct {
 
 	handle        http.Handler
 	wildcardNames []string
+	route         *Route // the registered route, set along with handle
+
+	// The class of the subtree of the node, see classify, and for the root
+	// the table of all classes of the tree.
+	class   uint32
+	classes map[string]uint32
 }
 
 // Increments priority of the given child and reorders if necessary
@@ -61,6 +66,19 @@ func (n *node) incrementLiteralPrio(pos int) int {
 // addRoute adds a node with the given handle to the path.
 // Not concurrency-safe!
 func (n *node) addRoute(path string, handle http.Handler) {
+	n.insertRoute(&Route{Path: path, Handler: handle})
+}
+
+// insertRoute adds a node with the handle of the given route to its path.
+// Not concurrency-safe!
+func (n *node) insertRoute(route *Route) {
+	// the tree may be changed even if the route is rejected
+	defer n.reclassify(route.Path)
+	n.insert(route)
+}
+
+func (n *node) insert(route *Route) {
+	path, handle := route.Path, route.Handler
 	fullpath := path
 	n.priority++
 
@@ -69,7 +87,7 @@ func (n *node) addRoute(path string, handle http.Handler) {
 	// Empty tree
 	if len(n.path) == 0 && len(n.indices) == 0 {
 		n.nType = root
-		n.insertChild(fullpath, path, handle, wildcardNames)
+		n.insertChild(path, route, wildcardNames)
 		return
 	}
 
@@ -89,6 +107,7 @@ walk:
 				catchAll:      n.catchAll,
 				wildcardNames: n.wildcardNames,
 				handle:        n.handle,
+				route:         n.route,
 				priority:      n.priority - 1,
 			}
 
@@ -99,6 +118,7 @@ walk:
 			n.indices = string([]byte{n.path[i]})
 			n.path = path[:i]
 			n.handle = nil
+			n.route = nil
 		}
 
 		// Move the path up
@@ -144,7 +164,7 @@ walk:
 				n.incrementLiteralPrio(len(n.indices) - 1)
 				n = child
 			}
-			n.insertChild(fullpath, path, handle, wildcardNames)
+			n.insertChild(path, route, wildcardNames)
 			return
 		}
 
@@ -154,11 +174,13 @@ walk:
 		}
 		n.handle = handle
 		n.wildcardNames = wildcardNames
+		n.route = route
 		return
 	}
 }
 
-func (n *node) insertChild(fullpath string, path string, handle http.Handler, wildcardNames []string) {
+func (n *node) insertChild(path string, route *Route, wildcardNames []string) {
+	fullpath, handle := route.Path, route.Handler
 	for {
 		// Find the prefix until first wildcard (: or *)
 		wildcard, i := findNextWildcard(path)
@@ -202,6 +224,7 @@ func (n *node) insertChild(fullpath string, path string, handle http.Handler, wi
 			// Otherwise we're done. Insert the handle in the new leaf
 			n.handle = handle
 			n.wildcardNames = wildcardNames
+			n.route = route
 			return
 
 		} else { // catchAll
@@ -222,6 +245,7 @@ func (n *node) insertChild(fullpath string, path string, handle http.Handler, wi
 				nType:         catchAll,
 				wildcardNames: wildcardNames,
 				handle:        handle,
+				route:         route,
 			}
 			n = n.catchAll
 			n.priority++
@@ -233,77 +257,330 @@ func (n *node) insertChild(fullpath string, path string, handle http.Handler, wi
 	n.path = path
 	n.handle = handle
 	n.wildcardNames = wildcardNames
+	n.route = route
 }
 
-// search recursively looks for a node at the given path
-func (n *node) search(path string) (*node, []string) {
-	// base case
-	if len(path) == 0 {
-		return n, nil
+// reclassify updates the classes of the tree after the route with the given
+// path was inserted. Only the nodes along the path of the route changed, the
+// classes of all others are kept.
+func (n *node) reclassify(path string) {
+	path, _ = normalizePath(path)
+	for child := n; child != nil; {
+		child.class = 0
+		if !strings.HasPrefix(path, child.path) {
+			break
+		}
+		path = path[len(child.path):]
+		if len(path) == 0 {
+			break
+		}
+
+		next := (*node)(nil)
+		switch {
+		case path[0] == ':':
+			next = child.wild
+		case path[0] == '*':
+			next = child.catchAll
+		case child.nType == param && len(child.literals) > 0:
+			next = child.literals[0]
+		default:
+			for i, c := range []byte(child.indices) {
+				if c == path[0] {
+					next = child.literals[i]
+					break
+				}
+			}
+		}
+		child = next
 	}
 
-	// the current node's prefix must match, otherwise it's already a miss
-	if i, nextChar, ok := escapeSafePrefixHelper(path, n.path); ok {
-		// consume the prefix:
-		// path = /topics and node prefix = /top
-		// then, strips `/top` from path
-		path = path[i:]
+	if n.classes == nil {
+		n.classes = make(map[string]uint32)
+	}
+	n.classify(n.classes)
+}
+
+// classify returns the class of the subtree of n, computing it if unknown.
+// Subtrees of the same class have the same shape, so that searching them for
+// the same path fails alike. This lets a search skip the subtrees of a class
+// already searched in vain at the same position of the path, see searcher.
+func (n *node) classify(classes map[string]uint32) uint32 {
+	if n.class != 0 {
+		return n.class
+	}
+
+	var b []byte
+	appendClass := func(tag byte, child *node) {
+		b = append(b, tag)
+		class := child.classify(classes)
+		b = append(b, byte(class), byte(class>>8), byte(class>>16), byte(class>>24))
+	}
+	b = append(b, n.path...)
+	b = append(b, 0)
+	if n.handle != nil {
+		b = append(b, 'h')
+	}
+	for i, child := range n.literals {
+		// the single literal of a param node isn't indexed
+		tag := byte(0)
+		if i < len(n.indices) {
+			tag = n.indices[i]
+		}
+		appendClass(tag, child)
+	}
+	if n.wild != nil {
+		appendClass(':', n.wild)
+	}
+	if n.catchAll != nil {
+		appendClass('*', n.catchAll)
+	}
 
-		// we've consumed the entire path; the current node is what we're looking for
+	class, ok := classes[string(b)]
+	if !ok {
+		class = uint32(len(classes) + 1)
+		classes[string(b)] = class
+	}
+	n.class = class
+	return class
+}
+
+// findRoute returns the node a route with the given normalized path would be
+// stored in, or nil if no such node exists yet.
+func (n *node) findRoute(path string) *node {
+	for {
+		if !strings.HasPrefix(path, n.path) {
+			return nil
+		}
+		path = path[len(n.path):]
 		if len(path) == 0 {
-			if n.handle != nil {
-				return n, nil
+			return n
+		}
+
+		next := (*node)(nil)
+		switch {
+		case path[0] == ':':
+			next = n.wild
+		case path[0] == '*':
+			next = n.catchAll
+		case n.nType == param && len(n.literals) > 0:
+			next = n.literals[0]
+		default:
+			for i, c := range []byte(n.indices) {
+				if c == path[0] {
+					next = n.literals[i]
+					break
+				}
 			}
-			return nil, nil
 		}
+		if next == nil {
+			return nil
+		}
+		n = next
+	}
+}
 
-		// we got more path to go; in priority order, try:
-		// - direct literal matches
-		// - named wildcards
-		// - catch all
+// collectStatic adds every node reachable through literal children only, and
+// thus registered without any wildcard, to routes keyed by its full path.
+func (n *node) collectStatic(prefix string, routes map[string]*node) {
+	prefix += n.path
+	if n.handle != nil && len(n.wildcardNames) == 0 {
+		routes[prefix] = n
+	}
+	for _, child := range n.literals {
+		child.collectStatic(prefix, routes)
+	}
+}
 
-		// direct literals
-		for i, c := range []byte(n.indices) {
-			if c == nextChar {
-				if found, params := n.literals[i].search(path); found != nil {
-					return found, params
-				}
+// collectRoutes appends the routes of n and all of its descendants.
+func (n *node) collectRoutes(routes []*Route) []*Route {
+	if n.handle != nil {
+		routes = append(routes, n.route)
+	}
+	for _, child := range n.literals {
+		routes = child.collectRoutes(routes)
+	}
+	if n.wild != nil {
+		routes = n.wild.collectRoutes(routes)
+	}
+	if n.catchAll != nil {
+		routes = n.catchAll.collectRoutes(routes)
+	}
+	return routes
+}
+
+// search recursively looks for a node at the given path.
+// If no node is found, tsr (trailing slash redirect) reports whether a handle
+// exists for the same path with an extra or without the trailing slash.
+//
+// A path can match both a literal and a wildcard at many positions, and the
+// backtracking would then try exponentially many combinations. The searcher
+// remembers which subtree classes failed at which position instead, see
+// classify, so that each class is searched at most once per position.
+func (n *node) search(path string) (found *node, params []string, tsr bool) {
+	var s searcher
+	return s.search(n, path)
+}
+
+// searcher holds the state of a single search.
+type searcher struct {
+	visits int // the number of nodes visited, for tests
+
+	// the failed searches, by the class of the node and the length of the
+	// rest of the path, with their tsr; the array avoids allocating for all
+	// but the most overlapping trees
+	fails    [16]searchFailure
+	numFails int
+	more     map[searchFailure]bool
+}
+
+type searchFailure struct {
+	class uint32
+	rest  int
+	tsr   bool
+}
+
+// failed looks up whether searching n for the rest of the path failed before.
+func (s *searcher) failed(n *node, path string) (failed, tsr bool) {
+	for _, f := range s.fails[:s.numFails] {
+		if f.class == n.class && f.rest == len(path) {
+			return true, f.tsr
+		}
+	}
+	tsr, failed = s.more[searchFailure{class: n.class, rest: len(path)}]
+	return failed, tsr
+}
+
+// fail records that searching n for the rest of the path failed.
+func (s *searcher) fail(n *node, path string, tsr bool) {
+	if s.numFails < len(s.fails) {
+		s.fails[s.numFails] = searchFailure{n.class, len(path), tsr}
+		s.numFails++
+		return
+	}
+	if s.more == nil {
+		s.more = make(map[searchFailure]bool)
+	}
+	s.more[searchFailure{class: n.class, rest: len(path)}] = tsr
+}
+
+func (s *searcher) search(n *node, path string) (found *node, params []string, tsr bool) {
+	s.visits++
+	// base case
+	if len(path) == 0 {
+		return n, nil, false
+	}
+
+	// the current node's prefix must match, otherwise it's already a miss
+	i, nextChar, ok := escapeSafePrefixHelper(path, n.path)
+	if !ok {
+		// the prefix might only be missing its trailing slash:
+		// path = /dir and node prefix = /dir/
+		if n.handle != nil && len(n.path) > 0 && n.path[len(n.path)-1] == '/' {
+			if i, _, ok := escapeSafePrefixHelper(path, n.path[:len(n.path)-1]); ok && i == len(path) {
+				tsr = true
 			}
 		}
+		return nil, nil, tsr
+	}
+
+	// consume the prefix:
+	// path = /topics and node prefix = /top
+	// then, strips `/top` from path
+	path = path[i:]
+
+	// we've consumed the entire path; the current node is what we're looking for
+	if len(path) == 0 {
+		if n.handle != nil {
+			return n, nil, false
+		}
+		return nil, nil, n.hasSlashChild()
+	}
+
+	// only the trailing slash is left over
+	if path == "/" && n.handle != nil {
+		tsr = true
+	}
 
-		// wildcard subpath
-		if n.wild != nil {
-			var token string
-			// Find param end (either '/' or path end)
-			if end := strings.IndexByte(path, '/'); end > 0 {
-				token = path[:end]
-				path = path[end:]
-			} else {
-				token = path
-				path = ""
+	// only nodes with both literals and a wildcard can make the search
+	// backtrack, remember what failed there
+	if n.wild != nil && len(n.indices) > 0 && n.class != 0 {
+		if failed, tsr := s.failed(n, path); failed {
+			return nil, nil, tsr
+		}
+		found, params, tsr = s.children(n, path, nextChar, tsr)
+		if found == nil {
+			s.fail(n, path, tsr)
+		}
+		return found, params, tsr
+	}
+	return s.children(n, path, nextChar, tsr)
+}
+
+// children searches the children of n for the rest of the path.
+func (s *searcher) children(n *node, path string, nextChar byte, tsr bool) (found *node, params []string, _ bool) {
+	// we got more path to go; in priority order, try:
+	// - direct literal matches
+	// - named wildcards
+	// - catch all
+
+	// direct literals
+	for i, c := range []byte(n.indices) {
+		if c == nextChar {
+			found, params, literalTsr := s.search(n.literals[i], path)
+			if found != nil {
+				return found, params, false
 			}
+			tsr = tsr || literalTsr
+		}
+	}
 
-			if len(path) > 0 {
+	// wildcard subpath
+	if n.wild != nil {
+		// Find param end (either '/' or path end); a param never matches an
+		// empty segment
+		end := strings.IndexByte(path, '/')
+		if end < 0 {
+			end = len(path)
+		}
+		if end > 0 {
+			token, rest := path[:end], path[end:]
+			if len(rest) > 0 {
 				if len(n.wild.literals) > 0 {
-					if wFound, wParams := n.wild.literals[0].search(path); wFound != nil {
+					wFound, wParams, wTsr := s.search(n.wild.literals[0], rest)
+					if wFound != nil {
 						params := []string{token}
 						params = append(params, wParams...)
-						return wFound, params
+						return wFound, params, false
 					}
+					tsr = tsr || wTsr
 				}
+				tsr = tsr || rest == "/" && n.wild.handle != nil
 			} else if n.wild.handle != nil {
-				return n.wild, []string{token}
+				return n.wild, []string{token}, false
+			} else if len(n.wild.literals) > 0 {
+				tsr = tsr || n.wild.literals[0].path == "/" && n.wild.literals[0].handle != nil
 			}
 		}
+	}
 
-		// catchall fallback
-		if n.catchAll != nil {
-			return n.catchAll, []string{path}
-		}
+	// catchall fallback
+	if n.catchAll != nil {
+		return n.catchAll, []string{path}, false
 	}
 
 	// didn't find anything
-	return nil, nil
+	return nil, nil, tsr
+}
+
+// hasSlashChild reports whether a handle is registered for the path of n with
+// an additional trailing slash.
+func (n *node) hasSlashChild() bool {
+	for i, c := range []byte(n.indices) {
+		if c == '/' {
+			return n.literals[i].path == "/" && n.literals[i].handle != nil
+		}
+	}
+	return false
 }
 
 func min(a, b int) int {
@@ -410,35 +687,29 @@ func denormalizePath(normalizedPath string, wildcardNames []string) string {
 }
 
 // given a potentially escaped path prefixHelper returns the index where the prefix
-// ends, the next character after the prefix, and whether we found the prefix
+// ends, the next character after the prefix, and whether we found the prefix.
+// Only the escapes within the prefix are decoded, so the work is linear in the
+// length of the prefix, not of the path.
 func escapeSafePrefixHelper(path, prefix string) (i int, next byte, ok bool) {
-	// always prefer the unescape version
-	unescapedPath, err := url.PathUnescape(path)
-	if err != nil {
-		unescapedPath = path
-	}
-
-	if strings.HasPrefix(unescapedPath, prefix) {
-		pathI := 0
-		prefixI := 0
-		// we know there is a match; count
-		for pathI < len(path) && prefixI < len(prefix) {
-			// direct match
-			if path[pathI] == prefix[prefixI] {
-				pathI++
-			} else {
-				// we found an encoding; its always of size 3
-				pathI += 3
-			}
-			prefixI++
+	for j := 0; j < len(prefix); j++ {
+		c, n := unescapedByte(path[i:])
+		if n == 0 || c != prefix[j] {
+			return 0, 0, false
 		}
-
-		if len(unescapedPath) > len(prefix) {
-			next = unescapedPath[len(prefix)]
-		}
-
-		return pathI, next, true
+		i += n
 	}
+	next, _ = unescapedByte(path[i:])
+	return i, next, true
+}
 
-	return
+// unescapedByte returns the first character of path, decoded if it is
+// escaped, and the number of bytes it takes up in path.
+func unescapedByte(path string) (c byte, n int) {
+	if len(path) == 0 {
+		return 0, 0
+	}
+	if path[0] == '%' && len(path) > 2 && isHex(path[1]) && isHex(path[2]) {
+		return unhex(path[1])<<4 | unhex(path[2]), 3
+	}
+	return path[0], 1
 }
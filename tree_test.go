@@ -10,6 +10,7 @@ import (
 	"fmt"
 	"net/http"
 	"reflect"
+	"strconv"
 	"strings"
 	"testing"
 )
@@ -67,7 +68,7 @@ type testRequests []struct {
 
 func checkRequests(t *testing.T, tree *node, requests testRequests) {
 	for _, request := range requests {
-		n, ps := tree.search(request.path)
+		n, ps, _ := tree.search(request.path)
 
 		if n == nil || n.handle == nil {
 			if !request.nilHandler {
@@ -509,3 +510,222 @@ func TestTreeWildcard_PartialLiteralMatch(t *testing.T) {
 
 	checkPriorities(t, tree)
 }
+
+func TestTreeTrailingSlashRedirect(t *testing.T) {
+	tree := &node{}
+
+	routes := [...]string{
+		"/hi",
+		"/b/",
+		"/search/:query",
+		"/cmd/:tool/",
+		"/src/*filepath",
+		"/x",
+		"/x/y",
+		"/y/",
+		"/y/z",
+		"/0/:id",
+		"/0/:id/1",
+		"/1/:id/",
+		"/1/:id/2",
+		"/aa",
+		"/a/",
+		"/admin",
+		"/admin/:category",
+		"/admin/:category/:page",
+		"/doc",
+		"/doc/go_faq.html",
+		"/doc/go1.html",
+		"/no/a",
+		"/no/b",
+		"/api/hello/:name/bar/",
+		"/api/bar/:name",
+		"/api/baz/foo",
+		"/api/baz/foo/bar",
+		"/blog/:p",
+		"/posts/:b/:c",
+		"/posts/b/:c/d/",
+	}
+	for _, route := range routes {
+		recv := catchPanic(func() {
+			tree.addRoute(route, fakeHandler(route))
+		})
+		if recv != nil {
+			t.Fatalf("panic inserting route '%s': %v", route, recv)
+		}
+	}
+
+	// printChildren(tree, "")
+
+	tsrRoutes := [...]string{
+		"/hi/",
+		"/b",
+		"/search/gopher/",
+		"/cmd/vet",
+		"/x/",
+		"/y",
+		"/0/go/",
+		"/1/go",
+		"/a",
+		"/admin/",
+		"/admin/config/",
+		"/admin/config/permissions/",
+		"/doc/",
+		"/api/hello/x/bar",
+		"/api/baz/foo/",
+		"/api/bar/huh/",
+		"/api/baz/foo/bar/",
+		"/blog/go/",
+		"/posts/b/c/d",
+	}
+	for _, route := range tsrRoutes {
+		found, _, tsr := tree.search(route)
+		if found != nil {
+			t.Fatalf("non-nil handler for TSR route '%s", route)
+		} else if !tsr {
+			t.Errorf("expected TSR recommendation for route '%s'", route)
+		}
+	}
+
+	noTsrRoutes := [...]string{
+		"/",
+		"/no",
+		"/no/",
+		"/src",
+		"/_",
+		"/_/",
+		"/api",
+		"/api/",
+		"/api/hello/x/foo",
+		"/api/baz/foo/bad",
+		"/api/baz/bax/",
+		"/foo/p/p",
+	}
+	for _, route := range noTsrRoutes {
+		found, _, tsr := tree.search(route)
+		if found != nil {
+			t.Fatalf("non-nil handler for No-TSR route '%s", route)
+		} else if tsr {
+			t.Errorf("expected no TSR recommendation for route '%s'", route)
+		}
+	}
+}
+
+func TestTreeEscapeSafePrefixHelper(t *testing.T) {
+	tests := []struct {
+		path, prefix string
+		i            int
+		next         byte
+		ok           bool
+	}{
+		{"/users/42", "/users/", 7, '4', true},
+		{"/users%2F42", "/users/", 9, '4', true},
+		{"/us%65rs/%34%32", "/users/", 9, '4', true},
+		{"/users/%7E", "/users/", 7, '~', true},
+		{"/users", "/users/", 0, 0, false},
+		{"/usa", "/users/", 0, 0, false},
+		{"/users%zz/", "/users/", 0, 0, false},
+		{"/100%/x", "/100%/", 6, 'x', true},
+		{"/100%25/x", "/100%/", 8, 'x', true},
+		{"/x", "", 0, '/', true},
+		{"", "", 0, 0, true},
+	}
+	for _, tt := range tests {
+		i, next, ok := escapeSafePrefixHelper(tt.path, tt.prefix)
+		if i != tt.i || next != tt.next || ok != tt.ok {
+			t.Errorf("%q, %q: got %d, %q, %t, want %d, %q, %t", tt.path, tt.prefix, i, next, ok, tt.i, tt.next, tt.ok)
+		}
+	}
+}
+
+// overlappingTree returns a tree with both a literal and a named parameter at
+// each of the given number of levels, and a route at the end of every
+// combination.
+func overlappingTree(levels int) *node {
+	tree := &node{}
+	paths := []string{""}
+	for i := 0; i < levels; i++ {
+		var next []string
+		for _, p := range paths {
+			next = append(next, p+"/a", p+"/:p"+strconv.Itoa(i))
+		}
+		paths = next
+	}
+	for _, p := range paths {
+		tree.addRoute(p+"/end", fakeHandler(p))
+	}
+	return tree
+}
+
+func TestTreeSearchBacktracking(t *testing.T) {
+	tree := overlappingTree(8)
+
+	// every combination is tried before the last one matches
+	path := strings.Repeat("/b", 7) + "/a/end"
+	n, params, _ := tree.search(path)
+	if n == nil || len(params) != 7 {
+		t.Fatalf("got node %v and params %v", n, params)
+	}
+
+	// a miss doesn't search the same subtrees again, and the escapes of the
+	// path are decoded without allocating
+	path = strings.Repeat("/%61", 8) + "/" + strings.Repeat("%61", 1000)
+	allocs := testing.AllocsPerRun(10, func() {
+		if n, _, _ := tree.search(path); n != nil {
+			t.Fatal("unexpected match")
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("search allocated %v times", allocs)
+	}
+}
+
+// treeDepth returns the number of nodes on the longest path from n to a leaf.
+func treeDepth(n *node) int {
+	depth := 0
+	children := append([]*node{n.wild, n.catchAll}, n.literals...)
+	for _, child := range children {
+		if child != nil {
+			if d := treeDepth(child); d > depth {
+				depth = d
+			}
+		}
+	}
+	return depth + 1
+}
+
+func TestTreeSearchVisits(t *testing.T) {
+	tree := overlappingTree(12)
+	depth := treeDepth(tree)
+	tests := []struct {
+		path  string
+		found bool
+		tsr   bool
+	}{
+		{strings.Repeat("/a", 12) + "/miss", false, false},
+		{strings.Repeat("/a", 12) + "/end/", false, true},
+		{strings.Repeat("/a", 11) + "/b/c", false, false},
+		{strings.Repeat("/%61", 12) + "/end", true, false},
+		{strings.Repeat("/b", 11) + "/a/end", true, false},
+		{strings.Repeat("/a/b", 6) + "/end", true, false},
+	}
+	for _, tt := range tests {
+		var s searcher
+		n, _, tsr := s.search(tree, tt.path)
+		if (n != nil) != tt.found || tsr != tt.tsr {
+			t.Errorf("%s: got node %v and tsr %t", tt.path, n, tsr)
+		}
+		if max := len(tt.path) * depth; s.visits > max {
+			t.Errorf("%s: visited %d nodes, want at most %d", tt.path, s.visits, max)
+		}
+	}
+}
+
+func BenchmarkTreeSearchBacktracking(b *testing.B) {
+	tree := overlappingTree(10)
+	path := strings.Repeat("/%61", 10) + "/miss"
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		tree.search(path)
+	}
+}
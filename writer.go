@@ -0,0 +1,218 @@
+package httprouter
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+)
+
+// responseWriter wraps an http.ResponseWriter to record the status code and
+// the number of bytes written. See wrapWriter for the optional interfaces.
+type responseWriter struct {
+	http.ResponseWriter
+	status  int
+	written int64
+}
+
+// wrapWriter wraps w in a responseWriter. The returned writer exposes the
+// optional interfaces of w among http.Flusher, http.Hijacker, http.Pusher and
+// http.CloseNotifier, and only those, so handlers can keep detecting what the
+// connection supports.
+func wrapWriter(w http.ResponseWriter) (*responseWriter, http.ResponseWriter) {
+	rw := &responseWriter{ResponseWriter: w}
+
+	var (
+		f    http.Flusher
+		h    http.Hijacker
+		p    http.Pusher
+		c    http.CloseNotifier
+		kind int
+	)
+	if _, ok := w.(http.Flusher); ok {
+		f, kind = flusher{rw}, kind|1
+	}
+	if _, ok := w.(http.Hijacker); ok {
+		h, kind = hijacker{rw}, kind|2
+	}
+	if pusher, ok := w.(http.Pusher); ok {
+		p, kind = pusher, kind|4
+	}
+	if notifier, ok := w.(http.CloseNotifier); ok {
+		c, kind = notifier, kind|8
+	}
+
+	switch kind {
+	case 1:
+		return rw, struct {
+			*responseWriter
+			http.Flusher
+		}{rw, f}
+	case 2:
+		return rw, struct {
+			*responseWriter
+			http.Hijacker
+		}{rw, h}
+	case 3:
+		return rw, struct {
+			*responseWriter
+			http.Flusher
+			http.Hijacker
+		}{rw, f, h}
+	case 4:
+		return rw, struct {
+			*responseWriter
+			http.Pusher
+		}{rw, p}
+	case 5:
+		return rw, struct {
+			*responseWriter
+			http.Flusher
+			http.Pusher
+		}{rw, f, p}
+	case 6:
+		return rw, struct {
+			*responseWriter
+			http.Hijacker
+			http.Pusher
+		}{rw, h, p}
+	case 7:
+		return rw, struct {
+			*responseWriter
+			http.Flusher
+			http.Hijacker
+			http.Pusher
+		}{rw, f, h, p}
+	case 8:
+		return rw, struct {
+			*responseWriter
+			http.CloseNotifier
+		}{rw, c}
+	case 9:
+		return rw, struct {
+			*responseWriter
+			http.Flusher
+			http.CloseNotifier
+		}{rw, f, c}
+	case 10:
+		return rw, struct {
+			*responseWriter
+			http.Hijacker
+			http.CloseNotifier
+		}{rw, h, c}
+	case 11:
+		return rw, struct {
+			*responseWriter
+			http.Flusher
+			http.Hijacker
+			http.CloseNotifier
+		}{rw, f, h, c}
+	case 12:
+		return rw, struct {
+			*responseWriter
+			http.Pusher
+			http.CloseNotifier
+		}{rw, p, c}
+	case 13:
+		return rw, struct {
+			*responseWriter
+			http.Flusher
+			http.Pusher
+			http.CloseNotifier
+		}{rw, f, p, c}
+	case 14:
+		return rw, struct {
+			*responseWriter
+			http.Hijacker
+			http.Pusher
+			http.CloseNotifier
+		}{rw, h, p, c}
+	case 15:
+		return rw, struct {
+			*responseWriter
+			http.Flusher
+			http.Hijacker
+			http.Pusher
+			http.CloseNotifier
+		}{rw, f, h, p, c}
+	}
+	return rw, rw
+}
+
+func (w *responseWriter) WriteHeader(code int) {
+	// informational responses may be followed by the final one
+	if w.status == 0 && code >= 200 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *responseWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(p)
+	w.written += int64(n)
+	return n, err
+}
+
+// ReadFrom keeps io.Copy able to use the optimized path of the wrapped
+// writer, like sendfile.
+func (w *responseWriter) ReadFrom(src io.Reader) (int64, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	var n int64
+	var err error
+	if rf, ok := w.ResponseWriter.(io.ReaderFrom); ok {
+		n, err = rf.ReadFrom(src)
+	} else {
+		n, err = io.Copy(writerOnly{w.ResponseWriter}, src)
+	}
+	w.written += n
+	return n, err
+}
+
+// flusher implements http.Flusher for a responseWriter wrapping a writer
+// which can flush.
+type flusher struct {
+	w *responseWriter
+}
+
+func (f flusher) Flush() {
+	if f.w.status == 0 {
+		f.w.status = http.StatusOK
+	}
+	f.w.ResponseWriter.(http.Flusher).Flush()
+}
+
+// hijacker implements http.Hijacker for a responseWriter wrapping a writer
+// which can be hijacked.
+type hijacker struct {
+	w *responseWriter
+}
+
+func (h hijacker) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := h.w.ResponseWriter.(http.Hijacker).Hijack()
+	if err == nil && h.w.status == 0 {
+		// the connection is gone; nothing must be written anymore
+		h.w.status = http.StatusSwitchingProtocols
+	}
+	return conn, rw, err
+}
+
+// Unwrap returns the wrapped writer, for http.ResponseController.
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// started reports whether the response was started.
+func (w *responseWriter) started() bool {
+	return w.status != 0
+}
+
+// writerOnly hides any optional interface of the wrapped writer, so io.Copy
+// doesn't call back into ReadFrom.
+type writerOnly struct {
+	io.Writer
+}
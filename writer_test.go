@@ -0,0 +1,102 @@
+package httprouter
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type hijackableRecorder struct {
+	*httptest.ResponseRecorder
+	hijacked bool
+}
+
+func (h *hijackableRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h.hijacked = true
+	return nil, nil, nil
+}
+
+type closeNotifyingRecorder struct {
+	*httptest.ResponseRecorder
+}
+
+func (c closeNotifyingRecorder) CloseNotify() <-chan bool {
+	return nil
+}
+
+func TestResponseWriter(t *testing.T) {
+	rec := httptest.NewRecorder()
+	w, ww := wrapWriter(rec)
+	if w.started() {
+		t.Fatal("fresh writer reported as started")
+	}
+
+	ww.WriteHeader(http.StatusCreated)
+	ww.WriteHeader(http.StatusOK) // superfluous
+	if w.status != http.StatusCreated {
+		t.Errorf("unexpected status %d", w.status)
+	}
+
+	ww.Write([]byte("hello "))
+	if n, err := ww.(io.ReaderFrom).ReadFrom(strings.NewReader("world")); n != 5 || err != nil {
+		t.Errorf("unexpected ReadFrom result %d, %v", n, err)
+	}
+	if w.written != 11 || rec.Body.String() != "hello world" {
+		t.Errorf("unexpected body %q (%d bytes)", rec.Body.String(), w.written)
+	}
+
+	ww.(http.Flusher).Flush()
+	if !rec.Flushed {
+		t.Error("flush not passed through")
+	}
+	if w.Unwrap() != rec {
+		t.Error("Unwrap did not return the wrapped writer")
+	}
+
+	h := &hijackableRecorder{ResponseRecorder: httptest.NewRecorder()}
+	w, ww = wrapWriter(h)
+	if _, _, err := ww.(http.Hijacker).Hijack(); err != nil || !h.hijacked {
+		t.Errorf("hijack not passed through: %v", err)
+	}
+	if !w.started() {
+		t.Error("hijacked writer not reported as started")
+	}
+}
+
+func TestResponseWriterInterfaces(t *testing.T) {
+	tests := []struct {
+		writer                                 http.ResponseWriter
+		flusher, hijacker, pusher, closeNotify bool
+	}{
+		{struct{ http.ResponseWriter }{httptest.NewRecorder()}, false, false, false, false},
+		{httptest.NewRecorder(), true, false, false, false},
+		{&hijackableRecorder{ResponseRecorder: httptest.NewRecorder()}, true, true, false, false},
+		{closeNotifyingRecorder{httptest.NewRecorder()}, true, false, false, true},
+	}
+	for i, tt := range tests {
+		_, w := wrapWriter(tt.writer)
+		_, flusher := w.(http.Flusher)
+		_, hijacker := w.(http.Hijacker)
+		_, pusher := w.(http.Pusher)
+		_, closeNotify := w.(http.CloseNotifier)
+		if flusher != tt.flusher || hijacker != tt.hijacker || pusher != tt.pusher || closeNotify != tt.closeNotify {
+			t.Errorf("%d: got interfaces %t %t %t %t", i, flusher, hijacker, pusher, closeNotify)
+		}
+	}
+}
+
+func TestRouterHooksUnwrappedWriter(t *testing.T) {
+	rec := httptest.NewRecorder()
+	router := New()
+	router.Hooks = HooksFunc(func(Event) {})
+	router.GET("/", func(w http.ResponseWriter, _ *http.Request) {
+		if w != rec {
+			t.Errorf("writer wrapped for hooks: %T", w)
+		}
+	})
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+}
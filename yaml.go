@@ -0,0 +1,166 @@
+package httprouter
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// jsonToYAML converts a JSON document to the equivalent block style YAML
+// document, keeping the order of object keys.
+func jsonToYAML(data []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	y := &yamlWriter{dec: dec}
+
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch tok {
+	case json.Delim('{'):
+		if !dec.More() {
+			y.buf.WriteString("{}\n")
+		}
+		err = y.writeObject(0, false)
+	case json.Delim('['):
+		if !dec.More() {
+			y.buf.WriteString("[]\n")
+		}
+		err = y.writeArray(0)
+	default:
+		y.buf.WriteString(yamlScalar(tok) + "\n")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return y.buf.Bytes(), nil
+}
+
+type yamlWriter struct {
+	dec *json.Decoder
+	buf bytes.Buffer
+}
+
+func (y *yamlWriter) indent(n int) {
+	for i := 0; i < n; i++ {
+		y.buf.WriteByte(' ')
+	}
+}
+
+// writeObject writes the entries of an object whose opening delimiter was
+// already read, up to and including its closing delimiter. If inline is true,
+// the first entry continues the current line.
+func (y *yamlWriter) writeObject(indent int, inline bool) error {
+	for first := true; y.dec.More(); first = false {
+		tok, err := y.dec.Token()
+		if err != nil {
+			return err
+		}
+		if !first || !inline {
+			y.indent(indent)
+		}
+		y.buf.WriteString(yamlScalar(tok) + ":")
+
+		if tok, err = y.dec.Token(); err != nil {
+			return err
+		}
+		if err = y.writeValue(tok, indent); err != nil {
+			return err
+		}
+	}
+	_, err := y.dec.Token()
+	return err
+}
+
+// writeArray writes the items of an array whose opening delimiter was already
+// read, up to and including its closing delimiter.
+func (y *yamlWriter) writeArray(indent int) error {
+	for y.dec.More() {
+		tok, err := y.dec.Token()
+		if err != nil {
+			return err
+		}
+		y.indent(indent)
+		y.buf.WriteByte('-')
+
+		if tok == json.Delim('{') && y.dec.More() {
+			y.buf.WriteByte(' ')
+			err = y.writeObject(indent+2, true)
+		} else {
+			err = y.writeValue(tok, indent)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	_, err := y.dec.Token()
+	return err
+}
+
+// writeValue writes the value starting with tok after a key or a dash.
+func (y *yamlWriter) writeValue(tok json.Token, indent int) error {
+	switch tok {
+	case json.Delim('{'):
+		if !y.dec.More() {
+			y.buf.WriteString(" {}\n")
+			_, err := y.dec.Token()
+			return err
+		}
+		y.buf.WriteByte('\n')
+		return y.writeObject(indent+2, false)
+	case json.Delim('['):
+		if !y.dec.More() {
+			y.buf.WriteString(" []\n")
+			_, err := y.dec.Token()
+			return err
+		}
+		y.buf.WriteByte('\n')
+		return y.writeArray(indent + 2)
+	default:
+		y.buf.WriteString(" " + yamlScalar(tok) + "\n")
+		return nil
+	}
+}
+
+// yamlScalar formats a scalar JSON token as YAML. Strings are only left
+// unquoted if they can't be mistaken for anything else.
+func yamlScalar(tok json.Token) string {
+	switch v := tok.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return fmt.Sprint(v)
+	case json.Number:
+		return v.String()
+	case string:
+		if yamlPlain(v) {
+			return v
+		}
+		quoted, _ := json.Marshal(v)
+		return string(quoted)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func yamlPlain(s string) bool {
+	if s == "" || s[len(s)-1] == ' ' {
+		return false
+	}
+	if c := s[0]; !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_' || c == '/') {
+		return false
+	}
+	for _, c := range []byte(s) {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' ||
+			c == '_' || c == '-' || c == '.' || c == '/' || c == ' ') {
+			return false
+		}
+	}
+	switch strings.ToLower(s) {
+	case "true", "false", "yes", "no", "on", "off", "null", "y", "n":
+		return false
+	}
+	return true
+}
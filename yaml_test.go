@@ -0,0 +1,35 @@
+package httprouter
+
+import "testing"
+
+func TestJSONToYAML(t *testing.T) {
+	data := `{"openapi":"3.0.3","info":{"title":"My API","version":"1.0"},"empty":{},"none":[],` +
+		`"tags":["a","yes",1.5,true,null],"items":[{"name":"x","in":"path"},[1,2]],"200":"OK: fine"}`
+	want := `openapi: "3.0.3"
+info:
+  title: My API
+  version: "1.0"
+empty: {}
+none: []
+tags:
+  - a
+  - "yes"
+  - 1.5
+  - true
+  - null
+items:
+  - name: x
+    in: path
+  -
+    - 1
+    - 2
+"200": "OK: fine"
+`
+	got, err := jsonToYAML([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want {
+		t.Errorf("unexpected YAML:\n%s\nwant:\n%s", got, want)
+	}
+}